@@ -0,0 +1,210 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricAdaptiveRate is the publish rate, in requests per second, set by adaptive rate limiting
+const MetricAdaptiveRate = "expo_adaptive_rate"
+
+// GaugeMetrics is implemented by Metrics that also record gauges. The client
+// reports values that go up and down, such as MetricAdaptiveRate, only to
+// Metrics implementing it.
+type GaugeMetrics interface {
+	SetGauge(name string, value float64, labels map[string]string)
+}
+
+// AdaptiveRateConfig configures adaptive rate limiting of publish requests.
+// The rate is cut by Decrease when Expo answers with 429 Too Many Requests or
+// a receipt reports MessageRateExceeded, and grows back by Increase per
+// second of requests sent without being limited (AIMD). Unset rates,
+// Increase and Decrease take their values from DefaultAdaptiveRateConfig.
+type AdaptiveRateConfig struct {
+	// InitialRate is the rate, in publish requests per second, to start with
+	InitialRate float64
+	// MinRate and MaxRate bound the rate
+	MinRate float64
+	MaxRate float64
+	// Increase is added to the rate for every second of successful requests
+	Increase float64
+	// Decrease multiplies the rate when rate limiting is reported; it is between 0 and 1
+	Decrease float64
+	// Cooldown is the least time between two decreases, so a burst of 429s counts once
+	Cooldown time.Duration
+}
+
+// DefaultAdaptiveRateConfig provides sensible defaults for adaptive rate limiting
+func DefaultAdaptiveRateConfig() *AdaptiveRateConfig {
+	return &AdaptiveRateConfig{
+		InitialRate: 10,
+		MinRate:     1,
+		MaxRate:     100,
+		Increase:    1,
+		Decrease:    0.5,
+		Cooldown:    time.Second,
+	}
+}
+
+// Validate reports settings that would keep the rate from adapting. Zero
+// values standing for the defaults are accepted.
+func (a *AdaptiveRateConfig) Validate() error {
+	var errs []error
+	if a.MinRate < 0 || a.MaxRate < 0 || a.InitialRate < 0 {
+		errs = append(errs, errors.New("rates must not be negative"))
+	}
+	if a.MaxRate > 0 && a.MaxRate < a.MinRate {
+		errs = append(errs, fmt.Errorf("MaxRate (%g) must not be less than MinRate (%g)", a.MaxRate, a.MinRate))
+	}
+	if a.InitialRate > 0 && (a.InitialRate < a.MinRate || (a.MaxRate > 0 && a.InitialRate > a.MaxRate)) {
+		errs = append(errs, fmt.Errorf("InitialRate must be between MinRate and MaxRate, got %g", a.InitialRate))
+	}
+	if a.Increase < 0 {
+		errs = append(errs, fmt.Errorf("Increase must not be negative, got %g", a.Increase))
+	}
+	if a.Decrease < 0 || a.Decrease >= 1 {
+		errs = append(errs, fmt.Errorf("Decrease must be between 0 and 1, got %g", a.Decrease))
+	}
+	if a.Cooldown < 0 {
+		errs = append(errs, fmt.Errorf("Cooldown must not be negative, got %s", a.Cooldown))
+	}
+	return errors.Join(errs...)
+}
+
+// adaptiveLimiter spaces publish requests at a rate adjusted by feedback from Expo
+type adaptiveLimiter struct {
+	cnf   AdaptiveRateConfig
+	clock Clock
+
+	mu           sync.Mutex
+	rate         float64
+	next         time.Time
+	lastDecrease time.Time
+}
+
+// newAdaptiveLimiter creates an adaptiveLimiter, taking unset rates from DefaultAdaptiveRateConfig
+func newAdaptiveLimiter(cnf *AdaptiveRateConfig, clock Clock) *adaptiveLimiter {
+	l := &adaptiveLimiter{cnf: *cnf, clock: clock}
+	defaults := DefaultAdaptiveRateConfig()
+	if l.cnf.MinRate <= 0 {
+		l.cnf.MinRate = defaults.MinRate
+	}
+	if l.cnf.MaxRate <= 0 {
+		l.cnf.MaxRate = max(defaults.MaxRate, l.cnf.MinRate)
+	}
+	if l.cnf.InitialRate <= 0 {
+		l.cnf.InitialRate = defaults.InitialRate
+	}
+	if l.cnf.Increase <= 0 {
+		l.cnf.Increase = defaults.Increase
+	}
+	if l.cnf.Decrease <= 0 || l.cnf.Decrease >= 1 {
+		l.cnf.Decrease = defaults.Decrease
+	}
+	l.rate = min(max(l.cnf.InitialRate, l.cnf.MinRate), l.cnf.MaxRate)
+	return l
+}
+
+// wait blocks until the next request may be sent at the current rate
+func (l *adaptiveLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(time.Duration(float64(time.Second) / l.rate))
+	l.mu.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(delay):
+		}
+	}
+	return nil
+}
+
+// succeeded raises the rate after a request that was not rate limited. Each
+// request adds Increase/rate, so the rate grows by Increase per second.
+func (l *adaptiveLimiter) succeeded() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = min(l.rate+l.cnf.Increase/l.rate, l.cnf.MaxRate)
+	return l.rate
+}
+
+// throttled lowers the rate after Expo reported rate limiting, unless it was
+// lowered less than Cooldown ago. It returns the rate and whether it changed.
+func (l *adaptiveLimiter) throttled() (float64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if !l.lastDecrease.IsZero() && now.Sub(l.lastDecrease) < l.cnf.Cooldown {
+		return l.rate, false
+	}
+	l.rate = max(l.rate*l.cnf.Decrease, l.cnf.MinRate)
+	l.lastDecrease = now
+	return l.rate, true
+}
+
+// currentRate returns the rate, in requests per second
+func (l *adaptiveLimiter) currentRate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// AdaptiveRate returns the current publish rate, in requests per second, or
+// zero if adaptive rate limiting is not enabled
+func (c *Client) AdaptiveRate() float64 {
+	if c.adaptive == nil {
+		return 0
+	}
+	return c.adaptive.currentRate()
+}
+
+// recordPublishStatus adjusts the adaptive rate to the status of a publish response
+func (c *Client) recordPublishStatus(ctx context.Context, status int) {
+	if c.adaptive == nil {
+		return
+	}
+	if status == http.StatusTooManyRequests {
+		c.recordRateLimited(ctx, "429 response")
+		return
+	}
+	if status >= http.StatusOK && status <= 299 {
+		c.setGauge(ctx, MetricAdaptiveRate, c.adaptive.succeeded(), nil)
+	}
+}
+
+// recordRateLimited lowers the adaptive rate because Expo reported rate limiting
+func (c *Client) recordRateLimited(ctx context.Context, reason string) {
+	if c.adaptive == nil {
+		return
+	}
+	rate, changed := c.adaptive.throttled()
+	if !changed {
+		return
+	}
+	c.log(ctx, LogInfo, "lowering publish rate", map[string]string{
+		"reason": reason,
+		"rate":   strconv.FormatFloat(rate, 'f', 2, 64),
+	})
+	c.setGauge(ctx, MetricAdaptiveRate, rate, nil)
+}
+
+func (c *Client) setGauge(ctx context.Context, name string, value float64, labels map[string]string) {
+	if gauges, ok := c.cnf.Metrics.(GaugeMetrics); ok {
+		gauges.SetGauge(name, value, withMetadata(ctx, labels))
+	}
+}
@@ -0,0 +1,97 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+)
+
+// Authorizer decides whether a message may be sent, e.g. by checking that all
+// of its recipients belong to the tenant making the call
+type Authorizer interface {
+	Authorize(ctx context.Context, msg *Message) error
+}
+
+// AuthorizerFunc adapts an ordinary function to the Authorizer interface
+type AuthorizerFunc func(ctx context.Context, msg *Message) error
+
+// Authorize calls f(ctx, msg)
+func (f AuthorizerFunc) Authorize(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// AuthorizationError is reported for every recipient of a message the Authorizer denied
+type AuthorizationError struct {
+	Err error
+}
+
+func (e *AuthorizationError) Error() string {
+	return fmt.Sprintf("message not authorized: %v", e.Err)
+}
+
+func (e *AuthorizationError) Unwrap() error {
+	return e.Err
+}
+
+// publishAuthorized sends the messages the Authorizer allows and reports an
+// AuthorizationError response for each recipient of the denied ones
+func (c *Client) publishAuthorized(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	return c.publishDenying(ctx, msgs, c.authorize(ctx, msgs))
+}
+
+// authorize returns an AuthorizationError for each message the Authorizer denies with ctx
+func (c *Client) authorize(ctx context.Context, msgs []*Message) map[*Message]error {
+	denials := make(map[*Message]error)
+	for _, msg := range msgs {
+		if err := c.cnf.Authorizer.Authorize(ctx, msg); err != nil {
+			denials[msg] = &AuthorizationError{Err: err}
+		}
+	}
+	return denials
+}
+
+// publishDenying sends the messages without denials and reports an
+// AuthorizationError response for each recipient of the denied ones
+func (c *Client) publishDenying(ctx context.Context, msgs []*Message, denials map[*Message]error) ([]*MessageResponse, error) {
+	if len(denials) == 0 {
+		return c.publishAllowed(ctx, msgs)
+	}
+	c.log(ctx, LogWarn, "messages denied by authorizer", map[string]string{"count": fmt.Sprint(len(denials))})
+
+	allowed := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		if _, denied := denials[msg]; !denied {
+			allowed = append(allowed, msg)
+		}
+	}
+
+	var sent []*MessageResponse
+	if len(allowed) > 0 {
+		var err error
+		if sent, err = c.publishAllowed(ctx, allowed); err != nil {
+			return nil, err
+		}
+	}
+	byMessage := make(map[*Message][]*MessageResponse, len(allowed))
+	for _, response := range sent {
+		byMessage[response.MessageItem] = append(byMessage[response.MessageItem], response)
+	}
+
+	var responses []*MessageResponse
+	for _, msg := range msgs {
+		err, denied := denials[msg]
+		if !denied {
+			responses = append(responses, byMessage[msg]...)
+			continue
+		}
+		for _, token := range msg.To {
+			responses = append(responses, &MessageResponse{
+				MessageItem: msg,
+				Token:       token,
+				Status:      "error",
+				Message:     err.Error(),
+				Err:         err,
+			})
+		}
+	}
+	return responses, nil
+}
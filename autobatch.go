@@ -0,0 +1,190 @@
+package expo
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// batchCall is a Publish call waiting in an auto batch
+type batchCall struct {
+	ctx    context.Context
+	msgs   []*Message
+	copies []*Message
+	done   chan batchReply
+}
+
+type batchReply struct {
+	responses []*MessageResponse
+	err       error
+}
+
+// autoBatcher coalesces small Publish calls into combined requests. Calls
+// made with different access tokens are kept in separate batches.
+type autoBatcher struct {
+	client   *Client
+	maxDelay time.Duration
+	maxSize  int
+
+	mu      sync.Mutex
+	batches map[string]*autoBatch
+}
+
+// autoBatch holds the calls waiting to be sent with one access token
+type autoBatch struct {
+	pending []*batchCall
+	size    int
+}
+
+func newAutoBatcher(client *Client, maxDelay time.Duration, maxSize int) *autoBatcher {
+	if maxSize <= 0 || maxSize > client.maxBatchSize() {
+		maxSize = client.maxBatchSize()
+	}
+	return &autoBatcher{client: client, maxDelay: maxDelay, maxSize: maxSize, batches: make(map[string]*autoBatch)}
+}
+
+// submit adds msgs to the current batch and waits for their responses
+func (b *autoBatcher) submit(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	weight := requestWeight(msgs)
+	if len(msgs) == 0 || weight >= b.maxSize {
+		return b.client.publish(ctx, msgs)
+	}
+	// Problems with one caller's messages must not fail the rest of the batch
+	if err := b.client.checkNil(msgs); err != nil {
+		return nil, err
+	}
+	// Warnings are logged when the combined batch is published
+	if err := b.client.rejectInvalid(msgs); err != nil {
+		return nil, err
+	}
+	// Each caller is authorized with its own context before joining a batch,
+	// which is sent with the context of another caller
+	if b.client.cnf.Authorizer != nil {
+		if denials := b.client.authorize(ctx, msgs); len(denials) > 0 {
+			return b.client.publishDenying(ctx, msgs, denials)
+		}
+	}
+
+	// Copies keep the responses of callers sharing a *Message apart
+	call := &batchCall{ctx: ctx, msgs: msgs, copies: make([]*Message, len(msgs)), done: make(chan batchReply, 1)}
+	if b.client.cnf.DedupeRecipients && b.client.cnf.DedupeAcrossBatch {
+		// Tokens are only removed across the messages of one call, never
+		// across the calls sharing a batch
+		var removed int
+		call.copies, removed = DeduplicateRecipients(msgs, true)
+		if removed > 0 {
+			b.client.incCounter(ctx, MetricDuplicateRecipients, float64(removed), map[string]string{"source": "batch"})
+			b.client.log(ctx, LogDebug, "removed duplicate recipients", map[string]string{"count": strconv.Itoa(removed)})
+		}
+	} else {
+		for i, msg := range msgs {
+			msgCopy := *msg
+			call.copies[i] = &msgCopy
+		}
+	}
+
+	token := b.client.accessToken(ctx)
+	b.mu.Lock()
+	var full []*batchCall
+	batch := b.batches[token]
+	if batch != nil && batch.size+weight > b.maxSize {
+		full = b.take(token)
+		batch = nil
+	}
+	if batch == nil {
+		batch = &autoBatch{}
+		b.batches[token] = batch
+		go b.flushAfter(token, batch)
+	}
+	batch.pending = append(batch.pending, call)
+	batch.size += weight
+	if batch.size == b.maxSize {
+		full = append(full, b.take(token)...)
+	}
+	b.mu.Unlock()
+
+	if len(full) > 0 {
+		go b.flush(full)
+	}
+
+	select {
+	case reply := <-call.done:
+		return reply.responses, reply.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// take removes the current batch of token and returns its calls; b.mu must be held
+func (b *autoBatcher) take(token string) []*batchCall {
+	batch := b.batches[token]
+	delete(b.batches, token)
+	return batch.pending
+}
+
+// flushAfter sends batch once maxDelay has passed, unless it has already
+// been sent because it filled up
+func (b *autoBatcher) flushAfter(token string, batch *autoBatch) {
+	<-b.client.cnf.Clock.After(b.maxDelay)
+	b.mu.Lock()
+	var calls []*batchCall
+	if b.batches[token] == batch {
+		calls = b.take(token)
+	}
+	b.mu.Unlock()
+	b.flush(calls)
+}
+
+// flush publishes the messages of calls in one request and hands each call its responses
+func (b *autoBatcher) flush(calls []*batchCall) {
+	if len(calls) == 0 {
+		return
+	}
+	var combined []*Message
+	for _, call := range calls {
+		for i, msgCopy := range call.copies {
+			// Messages whose recipients were all removed as duplicates are dropped
+			if len(msgCopy.To) == 0 && len(call.msgs[i].To) > 0 {
+				continue
+			}
+			combined = append(combined, msgCopy)
+		}
+	}
+
+	// The request outlives any single caller giving up, but keeps the call
+	// metadata of the first; all calls share its access token and were
+	// authorized with their own context in submit
+	ctx := context.WithoutCancel(calls[0].ctx)
+	var responses []*MessageResponse
+	err := b.client.safely(ctx, "auto batcher", func() (err error) {
+		if b.client.cnf.DedupeRecipients || b.client.cnf.DedupeStore != nil {
+			// Each call was deduplicated across its own messages in submit
+			responses, err = b.client.publishDeduplicated(ctx, combined, false)
+		} else {
+			responses, err = b.client.publishValidated(ctx, combined)
+		}
+		return err
+	})
+	if err != nil {
+		for _, call := range calls {
+			call.done <- batchReply{err: err}
+		}
+		return
+	}
+
+	byMessage := make(map[*Message][]*MessageResponse, len(combined))
+	for _, response := range responses {
+		byMessage[response.MessageItem] = append(byMessage[response.MessageItem], response)
+	}
+	for _, call := range calls {
+		var own []*MessageResponse
+		for i, msgCopy := range call.copies {
+			for _, response := range byMessage[msgCopy] {
+				response.MessageItem = call.msgs[i]
+				own = append(own, response)
+			}
+		}
+		call.done <- batchReply{responses: own}
+	}
+}
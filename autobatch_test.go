@@ -0,0 +1,68 @@
+package expo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestAutoBatchCountsRecipients(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	client := expo.NewClient(expo.WithHost(s.URL), expo.WithAutoBatch(20*time.Millisecond, 100))
+	ctx := context.Background()
+
+	parallel(4, func(i int) {
+		responses, err := client.PublishSingle(ctx, &expo.Message{To: testTokens(40), Body: "hello"})
+		if err != nil || len(responses) != 40 {
+			t.Errorf("PublishSingle() = %d responses, %v", len(responses), err)
+		}
+	})
+	total := 0
+	for _, req := range s.Requests() {
+		n := recipientCount(t, req.Body)
+		if n > 100 {
+			t.Errorf("sent a request to %d recipients", n)
+		}
+		total += n
+	}
+	if total != 160 {
+		t.Errorf("sent %d recipients, want 160", total)
+	}
+}
+
+func TestAutoBatchDeduplicatesEachCall(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	// Both calls fill the batch, so they are sent in one request
+	client := expo.NewClient(expo.WithHost(s.URL), expo.WithAutoBatch(time.Hour, 4),
+		expo.WithDeduplicateRecipients(true), expo.WithDeduplicateAcrossBatch(true))
+	ctx := context.Background()
+
+	calls := [][]*expo.Message{
+		{{To: []*expo.Token{testToken(0)}, Body: "a"}, {To: []*expo.Token{testToken(0), testToken(1)}, Body: "a"}},
+		{{To: []*expo.Token{testToken(0)}, Body: "b"}},
+	}
+	want := [][]*expo.Token{{testToken(0), testToken(1)}, {testToken(0)}}
+	parallel(len(calls), func(i int) {
+		responses, err := client.Publish(ctx, calls[i])
+		if err != nil {
+			t.Errorf("call %d: Publish() = %v", i, err)
+			return
+		}
+		if len(responses) != len(want[i]) {
+			t.Errorf("call %d: got %d responses, want %d", i, len(responses), len(want[i]))
+			return
+		}
+		for j, response := range responses {
+			if !response.IsOk() || *response.Token != *want[i][j] {
+				t.Errorf("call %d: response %d = %+v, want ok for %s", i, j, response, *want[i][j])
+			}
+		}
+	})
+
+	if reqs := s.Requests(); len(reqs) != 1 || recipientCount(t, reqs[0].Body) != 3 {
+		t.Errorf("sent %d requests, want one to 3 recipients", len(reqs))
+	}
+}
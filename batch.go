@@ -0,0 +1,370 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// maxNotificationsPerRequest is the number of messages Expo accepts in one publish request
+	maxNotificationsPerRequest = 100
+	// maxReceiptsPerRequest is the number of ticket IDs Expo accepts in one receipts request
+	maxReceiptsPerRequest = 1000
+)
+
+// maxBatchSize returns the number of messages the client sends in one publish request
+func (c *Client) maxBatchSize() int {
+	if c.cnf.MaxBatchSize <= 0 || c.cnf.MaxBatchSize > maxNotificationsPerRequest {
+		return maxNotificationsPerRequest
+	}
+	return c.cnf.MaxBatchSize
+}
+
+// requestWeight returns the number of notifications msgs count for toward the
+// limit of a publish request: one per recipient, and one for a message
+// without recipients, which still takes a place in the request
+func requestWeight(msgs []*Message) int {
+	n := 0
+	for _, msg := range msgs {
+		if msg == nil {
+			n++
+			continue
+		}
+		n += max(len(msg.To), 1)
+	}
+	return n
+}
+
+// chunkByWeight splits msgs, in order, into chunks weighing at most n; a
+// message weighing more than n makes a chunk of its own
+func chunkByWeight(msgs []*Message, n int) [][]*Message {
+	var chunks [][]*Message
+	var chunk []*Message
+	weight := 0
+	for _, msg := range msgs {
+		w := requestWeight([]*Message{msg})
+		if len(chunk) > 0 && weight+w > n {
+			chunks = append(chunks, chunk)
+			chunk, weight = nil, 0
+		}
+		chunk = append(chunk, msg)
+		weight += w
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// maxReceiptBatch returns the number of ticket IDs the client queries in one receipts request
+func (c *Client) maxReceiptBatch() int {
+	if c.cnf.MaxReceiptBatch <= 0 || c.cnf.MaxReceiptBatch > maxReceiptsPerRequest {
+		return maxReceiptsPerRequest
+	}
+	return c.cnf.MaxReceiptBatch
+}
+
+// ChunkStatus describes whether a chunk of a batch has been sent
+type ChunkStatus int
+
+const (
+	// ChunkPending means the chunk has not been attempted yet
+	ChunkPending ChunkStatus = iota
+	// ChunkSent means Expo accepted the chunk and returned push tickets for it
+	ChunkSent
+	// ChunkFailed means the publish request for the chunk failed
+	ChunkFailed
+)
+
+func (s ChunkStatus) String() string {
+	switch s {
+	case ChunkPending:
+		return "pending"
+	case ChunkSent:
+		return "sent"
+	case ChunkFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// ChunkReport records the outcome of publishing one chunk of a batch
+type ChunkReport struct {
+	Index     int
+	Messages  []*Message
+	Status    ChunkStatus
+	Responses []*MessageResponse
+	Err       error
+	// Credential is the name of the credential the chunk was published with, if sharded
+	Credential string
+	// Attempts is the number of times the chunk was published, including resumes
+	Attempts int
+	// Duration is how long the last attempt took
+	Duration time.Duration
+}
+
+// BatchReport tracks the per-chunk status of a batch send
+type BatchReport struct {
+	// Lane is the priority lane the batch is sent in
+	Lane   Lane
+	Chunks []*ChunkReport
+	// StartedAt is when the batch was first sent, and FinishedAt when the last send or resume returned
+	StartedAt  time.Time
+	FinishedAt time.Time
+
+	// originals maps the parts made by splitting a message to the message given to SendLane
+	originals map[*Message]*Message
+}
+
+// Complete returns true if every chunk has been sent
+func (r *BatchReport) Complete() bool {
+	for _, chunk := range r.Chunks {
+		if chunk.Status != ChunkSent {
+			return false
+		}
+	}
+	return true
+}
+
+// Unsent returns the chunks that are pending or failed
+func (r *BatchReport) Unsent() []*ChunkReport {
+	var unsent []*ChunkReport
+	for _, chunk := range r.Chunks {
+		if chunk.Status != ChunkSent {
+			unsent = append(unsent, chunk)
+		}
+	}
+	return unsent
+}
+
+// Responses returns the push tickets of all sent chunks, in chunk order
+func (r *BatchReport) Responses() []*MessageResponse {
+	var responses []*MessageResponse
+	for _, chunk := range r.Chunks {
+		responses = append(responses, chunk.Responses...)
+	}
+	return responses
+}
+
+// Err returns the errors of all failed chunks joined together, or nil
+func (r *BatchReport) Err() error {
+	var errs []error
+	for _, chunk := range r.Chunks {
+		if chunk.Status == ChunkFailed {
+			errs = append(errs, fmt.Errorf("chunk %d: %w", chunk.Index, chunk.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BatchConfig holds configuration for batch sending
+type BatchConfig struct {
+	// ChunkSize is the number of recipients per publish request; messages
+	// with more recipients are split with SplitRecipients
+	ChunkSize int
+	// Concurrency is the number of chunks published in parallel, shared by
+	// all batches the sender is publishing at the same time
+	Concurrency int
+	// Credentials are access tokens to publish chunks with instead of the
+	// client's own, as chosen by Sharding
+	Credentials []*Credential
+	// Sharding decides which of the Credentials publishes each chunk
+	Sharding ShardStrategy
+	// ProjectOf returns the project of a message, which ShardByProject looks
+	// up in Credential.Projects. Chunks only hold messages of one project.
+	ProjectOf func(msg *Message) string
+}
+
+// DefaultBatchConfig provides sensible defaults for batch sending
+func DefaultBatchConfig() *BatchConfig {
+	return &BatchConfig{
+		ChunkSize:   maxNotificationsPerRequest,
+		Concurrency: 4,
+	}
+}
+
+// BatchSender publishes any number of messages by splitting them into chunks
+// that fit a single publish request. Batches sent at the same time share the
+// sender's concurrency, and chunks of higher priority lanes are published first.
+type BatchSender struct {
+	client *Client
+	cnf    *BatchConfig
+	slots  *laneLimiter
+	shards *shards
+}
+
+// NewBatchSender creates a BatchSender publishing through client
+func NewBatchSender(client *Client, cnf *BatchConfig) *BatchSender {
+	if cnf == nil {
+		cnf = DefaultBatchConfig()
+	}
+	// Defaults are applied to a copy, leaving the caller's config as it is
+	c := *cnf
+	if c.ChunkSize <= 0 || c.ChunkSize > client.maxBatchSize() {
+		c.ChunkSize = client.maxBatchSize()
+	}
+	if c.Concurrency <= 0 {
+		c.Concurrency = 1
+	}
+	return &BatchSender{
+		client: client,
+		cnf:    &c,
+		slots:  newLaneLimiter(c.Concurrency),
+		shards: newShards(&c, client.cnf.Clock),
+	}
+}
+
+// Send publishes msgs in chunks. The returned report is never nil and records
+// which chunks were sent; pass it to ResumeFrom to send the rest after a failure.
+func (s *BatchSender) Send(ctx context.Context, msgs []*Message) (*BatchReport, error) {
+	return s.SendLane(ctx, LaneNormal, msgs)
+}
+
+// SendLane is like Send but publishes the chunks in the given priority lane
+func (s *BatchSender) SendLane(ctx context.Context, lane Lane, msgs []*Message) (*BatchReport, error) {
+	groups := [][]*Message{msgs}
+	if s.shards != nil && s.shards.strategy == ShardByProject {
+		groups = s.shards.groupByProject(msgs)
+	}
+
+	report := &BatchReport{Lane: lane}
+	for _, group := range groups {
+		var parts []*Message
+		for _, msg := range group {
+			if msg == nil || len(msg.To) <= s.cnf.ChunkSize {
+				parts = append(parts, msg)
+				continue
+			}
+			split := SplitRecipients(msg, s.cnf.ChunkSize)
+			if report.originals == nil {
+				report.originals = make(map[*Message]*Message)
+			}
+			for _, part := range split {
+				report.originals[part] = msg
+			}
+			parts = append(parts, split...)
+		}
+		for _, chunk := range chunkByWeight(parts, s.cnf.ChunkSize) {
+			report.addChunk(chunk)
+		}
+	}
+	return report, s.ResumeFrom(ctx, report)
+}
+
+// addChunk appends a pending chunk of msgs to the report
+func (r *BatchReport) addChunk(msgs []*Message) {
+	r.Chunks = append(r.Chunks, &ChunkReport{Index: len(r.Chunks), Messages: msgs})
+}
+
+// SplitRecipients returns copies of msg that each address at most n of its
+// recipients, in order, so that a message to more recipients than a publish
+// request accepts can be sent in several requests. n defaults to Expo's limit
+// of 100. A message within the limit is returned as is.
+func SplitRecipients(msg *Message, n int) []*Message {
+	if n <= 0 {
+		n = maxNotificationsPerRequest
+	}
+	if len(msg.To) <= n {
+		return []*Message{msg}
+	}
+	parts := make([]*Message, 0, (len(msg.To)+n-1)/n)
+	for start := 0; start < len(msg.To); start += n {
+		end := min(start+n, len(msg.To))
+		part := *msg
+		part.To = msg.To[start:end:end]
+		parts = append(parts, &part)
+	}
+	return parts
+}
+
+// ResumeFrom publishes the chunks of report that have not been sent yet, in
+// the report's lane, updating their status in place. The report must not be
+// read or resumed by another goroutine until ResumeFrom returns.
+func (s *BatchSender) ResumeFrom(ctx context.Context, report *BatchReport) error {
+	var wg sync.WaitGroup
+	labels := map[string]string{"lane": report.Lane.String()}
+	if report.StartedAt.IsZero() {
+		report.StartedAt = s.client.cnf.Clock.Now()
+	}
+	defer func() {
+		report.FinishedAt = s.client.cnf.Clock.Now()
+	}()
+
+	for _, chunk := range report.Unsent() {
+		start := s.client.cnf.Clock.Now()
+		if err := s.slots.acquire(ctx, report.Lane); err != nil {
+			wg.Wait()
+			return err
+		}
+		s.client.observeDuration(ctx, MetricLaneWait, s.client.cnf.Clock.Now().Sub(start), labels)
+
+		wg.Add(1)
+		go func(chunk *ChunkReport) {
+			defer func() {
+				s.slots.release()
+				wg.Done()
+			}()
+
+			chunk.Attempts++
+			attemptStart := s.client.cnf.Clock.Now()
+			var responses []*MessageResponse
+			err := s.client.safely(ctx, "batch sender", func() (err error) {
+				responses, err = s.publish(ctx, chunk)
+				return err
+			})
+			chunk.Duration = s.client.cnf.Clock.Now().Sub(attemptStart)
+			if err != nil {
+				chunk.Status, chunk.Err = ChunkFailed, err
+			} else {
+				for _, response := range responses {
+					if original, ok := report.originals[response.MessageItem]; ok {
+						response.MessageItem = original
+					}
+				}
+				chunk.Status, chunk.Responses, chunk.Err = ChunkSent, responses, nil
+			}
+			s.client.incCounter(ctx, MetricBatchChunks, 1, map[string]string{
+				"lane":   report.Lane.String(),
+				"status": chunk.Status.String(),
+			})
+		}(chunk)
+	}
+	wg.Wait()
+
+	return report.Err()
+}
+
+// publish sends the messages of chunk, with the credential chosen for it if sharded
+func (s *BatchSender) publish(ctx context.Context, chunk *ChunkReport) ([]*MessageResponse, error) {
+	if s.shards == nil {
+		return s.client.Publish(ctx, chunk.Messages)
+	}
+	sh, err := s.shards.pick(chunk)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Credential = sh.name
+
+	labels := map[string]string{"credential": sh.name}
+	start := s.client.cnf.Clock.Now()
+	if err := sh.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	s.client.observeDuration(ctx, MetricCredentialWait, s.client.cnf.Clock.Now().Sub(start), labels)
+
+	// Bypass auto batching, which could send the chunk with another credential's messages
+	responses, err := s.client.publish(WithAccessTokenOverride(ctx, sh.token), chunk.Messages)
+	status := ChunkSent
+	if err != nil {
+		status = ChunkFailed
+	}
+	s.client.incCounter(ctx, MetricCredentialChunks, 1, map[string]string{
+		"credential": sh.name,
+		"status":     status.String(),
+	})
+	return responses, err
+}
@@ -0,0 +1,38 @@
+package expo_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func BenchmarkSplitRecipients(b *testing.B) {
+	msg := &expo.Message{To: testTokens(5000), Body: "hello"}
+	b.ReportAllocs()
+	for range b.N {
+		if parts := expo.SplitRecipients(msg, 100); len(parts) != 50 {
+			b.Fatalf("SplitRecipients() = %d parts", len(parts))
+		}
+	}
+}
+
+func BenchmarkBatchSenderSend(b *testing.B) {
+	msgs := testMessages(1000)
+	for _, concurrency := range []int{1, 4} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			client := cannedClient(http.StatusOK, okTicketsBody(100))
+			sender := expo.NewBatchSender(client, &expo.BatchConfig{ChunkSize: 100, Concurrency: concurrency})
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := sender.Send(ctx, msgs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package expo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetryBudget limits the retries made by all calls sharing a client, so that
+// concurrent chunks retrying independently cannot multiply the load on Expo
+// during an incident. The limits apply per Window; zero values disable a limit.
+type RetryBudget struct {
+	// MaxRetries is the maximum number of retries per window
+	MaxRetries int
+	// MaxRatio is the maximum ratio of retries to requests per window
+	MaxRatio float64
+	// MinRetries is the number of retries per window allowed regardless of MaxRatio
+	MinRetries int
+	// Window is the period after which the counters are reset; defaults to one minute
+	Window time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	requests    int
+	retries     int
+}
+
+// RetryBudgetExhaustedError is returned instead of retrying when the client's
+// RetryBudget has no retries left
+type RetryBudgetExhaustedError struct {
+	// LastErr is the error of the last attempt
+	LastErr error
+}
+
+func (e *RetryBudgetExhaustedError) Error() string {
+	return fmt.Sprintf("retry budget exhausted: %v", e.LastErr)
+}
+
+func (e *RetryBudgetExhaustedError) Unwrap() error {
+	return e.LastErr
+}
+
+// resetIfExpired starts a new window if the current one has elapsed; b.mu must be held
+func (b *RetryBudget) resetIfExpired(now time.Time) {
+	window := b.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	if now.Sub(b.windowStart) >= window {
+		b.windowStart = now
+		b.requests = 0
+		b.retries = 0
+	}
+}
+
+// recordRequest counts an attempt against the budget
+func (b *RetryBudget) recordRequest(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resetIfExpired(now)
+	b.requests++
+}
+
+// tryRetry consumes a retry from the budget, returning false if none is left
+func (b *RetryBudget) tryRetry(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resetIfExpired(now)
+
+	if b.MaxRetries > 0 && b.retries >= b.MaxRetries {
+		return false
+	}
+	if b.MaxRatio > 0 && b.retries >= b.MinRetries &&
+		float64(b.retries+1) > b.MaxRatio*float64(b.requests) {
+		return false
+	}
+	b.retries++
+	return true
+}
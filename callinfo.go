@@ -0,0 +1,95 @@
+package expo
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+)
+
+// requestIDHeaders are the response headers searched, in order, for a request ID
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "Request-Id", "X-Amz-Cf-Id"}
+
+// RateLimit is the rate limit state reported by the response headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	// ResetAt is when the limit resets, or zero if the server did not say
+	ResetAt time.Time
+}
+
+// CallInfo describes one HTTP exchange with the Expo API. Include RequestID in
+// support tickets; watch RateLimit to act before requests get throttled.
+type CallInfo struct {
+	// Endpoint is the last path element of the URL, e.g. "send" or "getReceipts"
+	Endpoint   string
+	StatusCode int
+	RequestID  string
+	// RateLimit is nil if the response carried no rate limit headers
+	RateLimit *RateLimit
+	Latency   time.Duration
+	Header    http.Header
+}
+
+type callInfoKey struct{}
+
+// callInfo returns the CallInfo recorded for the request that produced resp
+func callInfo(resp *http.Response) *CallInfo {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	info, _ := resp.Request.Context().Value(callInfoKey{}).(*CallInfo)
+	return info
+}
+
+// fill records the response received for req after latency
+func (i *CallInfo) fill(req *http.Request, resp *http.Response, latency time.Duration, now time.Time) {
+	i.Endpoint = path.Base(req.URL.Path)
+	i.StatusCode = resp.StatusCode
+	i.RateLimit = parseRateLimit(resp.Header, now)
+	i.Latency = latency
+	i.Header = resp.Header
+	for _, name := range requestIDHeaders {
+		if id := resp.Header.Get(name); id != "" {
+			i.RequestID = id
+			break
+		}
+	}
+}
+
+// parseRateLimit reads the X-RateLimit-* headers, or their unprefixed draft
+// standard equivalents. Reset may be a number of seconds or a UNIX timestamp.
+func parseRateLimit(header http.Header, now time.Time) *RateLimit {
+	get := func(name string) (int64, bool) {
+		value := header.Get("X-RateLimit-" + name)
+		if value == "" {
+			value = header.Get("RateLimit-" + name)
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil
+	}
+
+	limit, hasLimit := get("Limit")
+	remaining, hasRemaining := get("Remaining")
+	if !hasLimit && !hasRemaining {
+		return nil
+	}
+	rl := &RateLimit{Limit: int(limit), Remaining: int(remaining)}
+	if reset, ok := get("Reset"); ok {
+		// Values this large cannot be a delay in seconds
+		if reset > 1_000_000_000 {
+			rl.ResetAt = time.Unix(reset, 0)
+		} else {
+			rl.ResetAt = now.Add(time.Duration(reset) * time.Second)
+		}
+	}
+	return rl
+}
+
+// withCallInfo attaches an empty CallInfo to ctx, to be filled in once the
+// response arrives and found again through the response's request
+func withCallInfo(ctx context.Context) (context.Context, *CallInfo) {
+	info := &CallInfo{}
+	return context.WithValue(ctx, callInfoKey{}, info), info
+}
@@ -0,0 +1,133 @@
+package expo
+
+import "time"
+
+// CampaignReport summarizes a whole batch run in a form meant to be stored as
+// JSON, e.g. as an audit artifact. Create it from the BatchReport of the run
+// with NewCampaignReport and add the workflow results, if any, with AddResults.
+type CampaignReport struct {
+	Name       string    `json:"name"`
+	Lane       string    `json:"lane"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	DurationMs int64     `json:"durationMs"`
+	Messages   int       `json:"messages"`
+	// Chunks counts the chunks by status, keyed by ChunkStatus.String
+	Chunks map[string]int `json:"chunks"`
+	// Retries is the number of times chunks were published again with ResumeFrom
+	Retries int `json:"retries"`
+	// Tickets and TicketErrors count the push tickets returned by Expo
+	Tickets      int `json:"tickets"`
+	TicketErrors int `json:"ticketErrors"`
+	// Delivered, ReceiptErrors and Pending count the results added with AddResults
+	Delivered     int `json:"delivered"`
+	ReceiptErrors int `json:"receiptErrors"`
+	Pending       int `json:"pending"`
+	// Errors counts the Expo error codes of tickets and receipts
+	Errors map[string]int `json:"errors"`
+	// UnregisteredTokens are the tokens reported as DeviceNotRegistered
+	UnregisteredTokens []string `json:"unregisteredTokens"`
+	// ChunkDetails has one entry per chunk, in order
+	ChunkDetails []CampaignChunk `json:"chunkDetails"`
+
+	unregistered map[string]bool
+}
+
+// CampaignChunk is the outcome of one chunk in a CampaignReport
+type CampaignChunk struct {
+	Index      int    `json:"index"`
+	Status     string `json:"status"`
+	Messages   int    `json:"messages"`
+	Tickets    int    `json:"tickets"`
+	Attempts   int    `json:"attempts"`
+	DurationMs int64  `json:"durationMs"`
+	Credential string `json:"credential,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewCampaignReport aggregates the chunks and push tickets of batch
+func NewCampaignReport(name string, batch *BatchReport) *CampaignReport {
+	r := &CampaignReport{
+		Name:               name,
+		Lane:               batch.Lane.String(),
+		StartedAt:          batch.StartedAt,
+		FinishedAt:         batch.FinishedAt,
+		DurationMs:         batch.FinishedAt.Sub(batch.StartedAt).Milliseconds(),
+		Chunks:             make(map[string]int),
+		Errors:             make(map[string]int),
+		UnregisteredTokens: []string{},
+		ChunkDetails:       make([]CampaignChunk, 0, len(batch.Chunks)),
+		unregistered:       make(map[string]bool),
+	}
+
+	for _, chunk := range batch.Chunks {
+		r.Messages += len(chunk.Messages)
+		r.Chunks[chunk.Status.String()]++
+		r.Retries += max(chunk.Attempts-1, 0)
+		detail := CampaignChunk{
+			Index:      chunk.Index,
+			Status:     chunk.Status.String(),
+			Messages:   len(chunk.Messages),
+			Tickets:    len(chunk.Responses),
+			Attempts:   chunk.Attempts,
+			DurationMs: chunk.Duration.Milliseconds(),
+			Credential: chunk.Credential,
+		}
+		if chunk.Err != nil {
+			detail.Error = RedactToken(chunk.Err.Error())
+		}
+		r.ChunkDetails = append(r.ChunkDetails, detail)
+
+		for _, ticket := range chunk.Responses {
+			r.Tickets++
+			if ticket.IsOk() {
+				continue
+			}
+			r.TicketErrors++
+			r.countError(string(ticket.ErrorCode()), ticket.Token)
+		}
+	}
+	return r
+}
+
+// AddResults adds the receipt outcomes of workflow results for the tickets of the run
+func (r *CampaignReport) AddResults(results []*PushResult) {
+	for _, result := range results {
+		if result.PushReceipt == nil {
+			if result.Classify() == ClassPending {
+				r.Pending++
+			}
+			continue
+		}
+		if result.PushReceipt.IsOk() {
+			r.Delivered++
+			continue
+		}
+		r.ReceiptErrors++
+		r.countError(string(result.PushReceipt.ErrorCode()), result.Token)
+	}
+}
+
+// countError records the error code of a ticket or receipt for token
+func (r *CampaignReport) countError(code string, token *Token) {
+	if code == "" {
+		return
+	}
+	if r.Errors == nil {
+		r.Errors = make(map[string]int)
+	}
+	r.Errors[code]++
+	if code != string(ErrorMsgDeviceNotRegistered) || token == nil {
+		return
+	}
+	if r.unregistered == nil {
+		r.unregistered = make(map[string]bool)
+		for _, t := range r.UnregisteredTokens {
+			r.unregistered[t] = true
+		}
+	}
+	if !r.unregistered[string(*token)] {
+		r.unregistered[string(*token)] = true
+		r.UnregisteredTokens = append(r.UnregisteredTokens, string(*token))
+	}
+}
@@ -0,0 +1,113 @@
+package expo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// NotificationCategory describes a category of notifications and the action
+// buttons shown with them. Categories are registered on the device by the app
+// (e.g. with setNotificationCategoryAsync) and referenced by Message.CategoryID.
+type NotificationCategory struct {
+	Identifier string               `json:"identifier"`
+	Actions    []NotificationAction `json:"actions"`
+	Options    *CategoryOptions     `json:"options,omitempty"`
+}
+
+// NotificationAction is a button displayed with notifications of a category
+type NotificationAction struct {
+	Identifier  string           `json:"identifier"`
+	ButtonTitle string           `json:"buttonTitle"`
+	TextInput   *ActionTextInput `json:"textInput,omitempty"`
+	Options     *ActionOptions   `json:"options,omitempty"`
+}
+
+// ActionTextInput turns an action into a text input field
+type ActionTextInput struct {
+	SubmitButtonTitle string `json:"submitButtonTitle"`
+	Placeholder       string `json:"placeholder"`
+}
+
+// ActionOptions controls how an action behaves when tapped
+type ActionOptions struct {
+	// IsDestructive displays the action in red on iOS
+	IsDestructive bool `json:"isDestructive,omitempty"`
+	// IsAuthenticationRequired requires the device to be unlocked on iOS
+	IsAuthenticationRequired bool `json:"isAuthenticationRequired,omitempty"`
+	// OpensAppToForeground defaults to true on the device when omitted
+	OpensAppToForeground *bool `json:"opensAppToForeground,omitempty"`
+}
+
+// CategoryOptions holds iOS only category options
+type CategoryOptions struct {
+	PreviewPlaceholder    string   `json:"previewPlaceholder,omitempty"`
+	IntentIdentifiers     []string `json:"intentIdentifiers,omitempty"`
+	CategorySummaryFormat string   `json:"categorySummaryFormat,omitempty"`
+	CustomDismissAction   bool     `json:"customDismissAction,omitempty"`
+	AllowInCarPlay        bool     `json:"allowInCarPlay,omitempty"`
+	ShowTitle             bool     `json:"showTitle,omitempty"`
+	ShowSubtitle          bool     `json:"showSubtitle,omitempty"`
+	AllowAnnouncement     bool     `json:"allowAnnouncement,omitempty"`
+}
+
+// Validate checks that the category and its actions are well formed
+func (c *NotificationCategory) Validate() error {
+	if c.Identifier == "" {
+		return errors.New("category identifier must not be empty")
+	}
+	seen := make(map[string]bool, len(c.Actions))
+	for i, action := range c.Actions {
+		if action.Identifier == "" {
+			return fmt.Errorf("category %s: action %d: identifier must not be empty", c.Identifier, i)
+		}
+		if action.ButtonTitle == "" {
+			return fmt.Errorf("category %s: action %s: button title must not be empty", c.Identifier, action.Identifier)
+		}
+		if seen[action.Identifier] {
+			return fmt.Errorf("category %s: duplicate action %s", c.Identifier, action.Identifier)
+		}
+		seen[action.Identifier] = true
+	}
+	return nil
+}
+
+// MarshalCategories validates categories and serializes them as JSON for
+// distribution to client apps, which register them on the device
+func MarshalCategories(categories []*NotificationCategory) ([]byte, error) {
+	seen := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		if err := category.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[category.Identifier] {
+			return nil, fmt.Errorf("duplicate category %s", category.Identifier)
+		}
+		seen[category.Identifier] = true
+	}
+	return json.Marshal(categories)
+}
+
+// validateCategories reports messages whose CategoryID is not one of the
+// categories configured with WithCategories
+func (c *Client) validateCategories(msgs []*Message) []ValidationIssue {
+	if len(c.cnf.Categories) == 0 {
+		return nil
+	}
+
+	var issues []ValidationIssue
+	for i, msg := range msgs {
+		if msg.CategoryID == "" {
+			continue
+		}
+		if _, ok := c.cnf.Categories[msg.CategoryID]; !ok {
+			issues = append(issues, ValidationIssue{
+				Index:    i,
+				Field:    "categoryId",
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("unknown notification category: %s", msg.CategoryID),
+			})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,56 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// TokenRepository is the application's store of push tokens
+type TokenRepository interface {
+	// Delete removes tokens that can no longer receive notifications
+	Delete(ctx context.Context, tokens []Token) error
+}
+
+// TokenRepositoryFunc adapts an ordinary function to the TokenRepository interface
+type TokenRepositoryFunc func(ctx context.Context, tokens []Token) error
+
+// Delete calls f(ctx, tokens)
+func (f TokenRepositoryFunc) Delete(ctx context.Context, tokens []Token) error {
+	return f(ctx, tokens)
+}
+
+// CollectUnregisteredTokens returns, without duplicates and in result order, the
+// tokens whose ticket or receipt reported DeviceNotRegistered. Notifications to
+// these tokens will never be delivered and they should be deleted.
+func CollectUnregisteredTokens(results []*PushResult) []Token {
+	var tokens []Token
+	seen := make(map[Token]bool)
+	for _, result := range results {
+		if result.Token == nil || result.ErrorCode() != string(ErrorMsgDeviceNotRegistered) {
+			continue
+		}
+		if !seen[*result.Token] {
+			seen[*result.Token] = true
+			tokens = append(tokens, *result.Token)
+		}
+	}
+	return tokens
+}
+
+// cleanupTokens deletes the unregistered tokens among results from the
+// repository set with WithAutoTokenCleanup, if any
+func (c *Client) cleanupTokens(ctx context.Context, results []*PushResult) error {
+	if c.cnf.TokenRepository == nil {
+		return nil
+	}
+	tokens := CollectUnregisteredTokens(results)
+	if len(tokens) == 0 {
+		return nil
+	}
+	if err := c.cnf.TokenRepository.Delete(ctx, tokens); err != nil {
+		return fmt.Errorf("failed to delete unregistered tokens: %w", err)
+	}
+	c.log(ctx, LogInfo, "deleted unregistered tokens", map[string]string{"count": strconv.Itoa(len(tokens))})
+	return nil
+}
@@ -1,17 +1,29 @@
 package expo
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// Client sends push notifications and fetches receipts. A Client is safe for
+// concurrent use by multiple goroutines and should be reused rather than
+// created per call. Its configuration, including values passed by pointer such
+// as a RetryConfig, must not be modified after NewClient returns; state shared
+// between calls, such as a RetryBudget, synchronizes itself.
 type Client struct {
-	cnf *Config
+	cnf    *Config
+	dumpMu sync.Mutex
+	// inflight holds a slot per HTTP request in flight if MaxConcurrentRequests is set
+	inflight chan struct{}
+	batcher  *autoBatcher
+	adaptive *adaptiveLimiter
 }
 
 func NewClient(opts ...Option) *Client {
@@ -22,7 +34,27 @@ func NewClient(opts ...Option) *Client {
 		}
 	}
 	withDefaults(c)
-	return &Client{c}
+	client := &Client{cnf: c}
+	if c.MaxConcurrentRequests > 0 {
+		client.inflight = make(chan struct{}, c.MaxConcurrentRequests)
+	}
+	if c.AdaptiveRate != nil {
+		client.adaptive = newAdaptiveLimiter(c.AdaptiveRate, c.Clock)
+	}
+	if c.AutoBatchDelay > 0 {
+		client.batcher = newAutoBatcher(client, c.AutoBatchDelay, c.AutoBatchSize)
+	}
+	return client
+}
+
+// NewClientStrict is like NewClient but validates the resulting configuration,
+// returning an error that lists every invalid setting instead of a client
+func NewClientStrict(opts ...Option) (*Client, error) {
+	client := NewClient(opts...)
+	if err := client.cnf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid client configuration: %w", err)
+	}
+	return client, nil
 }
 
 // Publish sends a single push notification
@@ -30,7 +62,7 @@ func NewClient(opts ...Option) *Client {
 // @return an array of MessageResponse objects which contains the results.
 // @return error if any requests failed
 func (c *Client) PublishSingle(ctx context.Context, msg *Message) ([]*MessageResponse, error) {
-	responses, err := c.publish(ctx, []*Message{msg})
+	responses, err := c.Publish(ctx, []*Message{msg})
 	if err != nil {
 		return nil, err
 	}
@@ -42,68 +74,140 @@ func (c *Client) PublishSingle(ctx context.Context, msg *Message) ([]*MessageRes
 // @return an array of MessageResponse objects which contains the results.
 // @return error if the request failed
 func (c *Client) Publish(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	if c.batcher != nil {
+		return c.batcher.submit(ctx, msgs)
+	}
 	return c.publish(ctx, msgs)
 }
 
 func (c *Client) publish(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
-	// Validate the messages
-	for _, message := range msgs {
-		if len(message.To) == 0 {
-			return nil, errors.New("no recipients")
+	if err := c.checkNil(msgs); err != nil {
+		return nil, err
+	}
+	if c.cnf.Authorizer != nil {
+		return c.publishAuthorized(ctx, msgs)
+	}
+	return c.publishAllowed(ctx, msgs)
+}
+
+// endpointURL returns the URL of an endpoint: override if it is a full URL,
+// override resolved against the host if it is a path, or else the default
+// path below ApiURL
+func (c *Client) endpointURL(override, defaultPath string) string {
+	switch {
+	case override == "":
+		return c.cnf.Host + c.cnf.ApiURL + defaultPath
+	case strings.Contains(override, "://"):
+		return override
+	case !strings.HasPrefix(override, "/"):
+		override = "/" + override
+	}
+	return c.cnf.Host + override
+}
+
+// checkNil rejects nil messages, which cannot be sent in any validation mode.
+// With validation off it also rejects nil tokens, since Expo would refuse the
+// whole request because of them; the other modes report them as issues.
+func (c *Client) checkNil(msgs []*Message) error {
+	for i, msg := range msgs {
+		if msg == nil {
+			return ValidationIssue{Index: i, Field: "message", Severity: SeverityError, Message: "missing message"}
+		}
+		if c.cnf.ValidationMode != ValidationOff {
+			continue
 		}
-		for _, recipient := range message.To {
-			if recipient == nil || *recipient == "" {
-				return nil, errors.New("invalid push token")
+		for j, token := range msg.To {
+			if token == nil {
+				return ValidationIssue{Index: i, Field: fmt.Sprintf("to[%d]", j), Severity: SeverityError, Message: "missing push token"}
 			}
 		}
 	}
+	return nil
+}
 
-	// Limit to 100 notifications per request as per Expo documentation
-	const maxNotificationsPerRequest = 100
-	if len(msgs) > maxNotificationsPerRequest {
-		return nil, fmt.Errorf("too many notifications: %d (maximum is %d)", len(msgs), maxNotificationsPerRequest)
-	}
-
-	url := fmt.Sprintf("%s%s/push/send", c.cnf.Host, c.cnf.ApiURL)
-	jsonBytes, err := json.Marshal(msgs)
-	if err != nil {
-		return nil, err
+// publishAllowed deduplicates and validates msgs as configured and sends them
+func (c *Client) publishAllowed(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	if c.cnf.DedupeRecipients || c.cnf.DedupeStore != nil {
+		return c.publishDeduplicated(ctx, msgs, true)
 	}
+	return c.publishValidated(ctx, msgs)
+}
 
-	// Apply gzip compression if enabled
-	var requestBody []byte = jsonBytes
-	if c.cnf.EnableGzip {
-		var buf bytes.Buffer
-		gzWriter := gzip.NewWriter(&buf)
-		if _, err := gzWriter.Write(jsonBytes); err != nil {
+// publishValidated validates msgs according to the configured mode and sends them
+func (c *Client) publishValidated(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	switch c.cnf.ValidationMode {
+	case ValidationBasic:
+		if err := validateBasic(msgs); err != nil {
 			return nil, err
 		}
-		if err := gzWriter.Close(); err != nil {
+	case ValidationStrict:
+		warnings, err := c.validateStrict(msgs)
+		if err != nil {
 			return nil, err
 		}
-		requestBody = buf.Bytes()
+		for _, warning := range warnings {
+			c.log(ctx, LogWarn, "message validation warning", map[string]string{"issue": warning.Error()})
+		}
+	case ValidationLenient:
+		return c.publishLenient(ctx, msgs)
 	}
+	return c.send(ctx, msgs)
+}
 
-	// Use retry logic for the HTTP request
-	resp, err := c.WithRetry(ctx, c.cnf.RetryConfig, func() (*http.Response, error) {
-		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(requestBody))
-		if err != nil {
-			return nil, err
+// validateStrict returns the first validation issue of error severity, if
+// any, or else the warnings found
+func (c *Client) validateStrict(msgs []*Message) ([]ValidationIssue, error) {
+	issues := append(c.validateMessages(msgs), c.validateCategories(msgs)...)
+	var warnings []ValidationIssue
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return nil, issue
 		}
+		warnings = append(warnings, issue)
+	}
+	return warnings, nil
+}
 
-		req.Header.Add("Content-Type", "application/json")
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Accept-Encoding", "gzip, deflate")
+// rejectInvalid returns the error publishing msgs would fail with in the
+// basic and strict validation modes, for callers that send them later
+func (c *Client) rejectInvalid(msgs []*Message) error {
+	switch c.cnf.ValidationMode {
+	case ValidationBasic:
+		return validateBasic(msgs)
+	case ValidationStrict:
+		_, err := c.validateStrict(msgs)
+		return err
+	}
+	return nil
+}
 
-		if c.cnf.EnableGzip {
-			req.Header.Add("Content-Encoding", "gzip")
-		}
+// send publishes msgs in a single request without validating them
+func (c *Client) send(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	// Limit to 100 notifications per request as per Expo documentation, or
+	// fewer if configured. Expo counts every recipient of a message.
+	if n := requestWeight(msgs); n > c.maxBatchSize() {
+		return nil, fmt.Errorf("too many notifications: %d recipients (maximum is %d per request); split messages with SplitRecipients or send them with a BatchSender", n, c.maxBatchSize())
+	}
 
-		if c.cnf.AccessToken != "" {
-			req.Header.Add("Authorization", "Bearer "+c.cnf.AccessToken)
-		}
+	url := c.endpointURL(c.cnf.SendEndpoint, "/push/send")
+	newBody, compressed, release, err := c.prepareBody(msgs)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-		return c.cnf.HttpClient.Do(req)
+	// Use retry logic for the HTTP request
+	resp, err := c.WithRetry(ctx, c.cnf.RetryConfig, func() (*http.Response, error) {
+		return c.hedged(ctx, func(ctx context.Context) (*http.Response, error) {
+			if err := c.adaptive.wait(ctx); err != nil {
+				return nil, err
+			}
+			resp, err := c.post(ctx, url, newBody(), compressed)
+			if err == nil {
+				c.recordPublishStatus(ctx, resp.StatusCode)
+			}
+			return resp, err
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -115,8 +219,7 @@ func (c *Client) publish(ctx context.Context, msgs []*Message) ([]*MessageRespon
 	}
 
 	var r *Response
-	err = json.NewDecoder(resp.Body).Decode(&r)
-	if err != nil {
+	if err = c.decodeResponse(resp.Body, &r); err != nil {
 		return nil, err
 	}
 	if r.Errors != nil {
@@ -125,12 +228,17 @@ func (c *Client) publish(ctx context.Context, msgs []*Message) ([]*MessageRespon
 	if r.Data == nil {
 		return nil, NewServerError("invalid server response", resp, r, nil)
 	}
+	if err = c.checkTickets(r.Data); err != nil {
+		return nil, err
+	}
 
 	// Expand the messages to match the API's response structure
 	var expandedMessages []*Message
+	var expandedTokens []*Token
 	for _, msg := range msgs {
-		for range msg.To {
+		for _, token := range msg.To {
 			expandedMessages = append(expandedMessages, msg)
+			expandedTokens = append(expandedTokens, token)
 		}
 	}
 
@@ -140,8 +248,11 @@ func (c *Client) publish(ctx context.Context, msgs []*Message) ([]*MessageRespon
 	}
 	// data will contain an array of push tickets in the same order in which the messages were sent
 	// assign each response to its corresponding message
+	call := callInfo(resp)
 	for i := range r.Data {
 		r.Data[i].MessageItem = expandedMessages[i]
+		r.Data[i].Token = expandedTokens[i]
+		r.Data[i].Call = call
 	}
 	return r.Data, nil
 }
@@ -156,31 +267,23 @@ func (c *Client) GetPushReceipts(ctx context.Context, ticketIDs []string) (map[s
 		return make(map[string]*PushReceipt), nil
 	}
 
-	// The API accepts maximum 1000 receipt IDs per request
-	const maxReceiptsPerRequest = 1000
-	if len(ticketIDs) > maxReceiptsPerRequest {
-		return nil, fmt.Errorf("too many ticket IDs: %d (maximum is %d)", len(ticketIDs), maxReceiptsPerRequest)
+	// The API accepts maximum 1000 receipt IDs per request, or fewer if configured
+	if len(ticketIDs) > c.maxReceiptBatch() {
+		return nil, fmt.Errorf("too many ticket IDs: %d (maximum is %d)", len(ticketIDs), c.maxReceiptBatch())
 	}
 
-	url := fmt.Sprintf("%s%s/push/getReceipts", c.cnf.Host, c.cnf.ApiURL)
+	url := c.endpointURL(c.cnf.ReceiptsEndpoint, "/push/getReceipts")
 	reqBody := &PushReceiptRequest{IDs: ticketIDs}
 
-	jsonBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonBytes))
+	newBody, compressed, release, err := c.prepareBody(reqBody)
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 
-	req.Header.Add("Content-Type", "application/json")
-	if c.cnf.AccessToken != "" {
-		req.Header.Add("Authorization", "Bearer "+c.cnf.AccessToken)
-	}
-
-	resp, err := c.cnf.HttpClient.Do(req)
+	resp, err := c.WithRetry(ctx, c.cnf.RetryConfig, func() (*http.Response, error) {
+		return c.post(ctx, url, newBody(), compressed)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -191,18 +294,124 @@ func (c *Client) GetPushReceipts(ctx context.Context, ticketIDs []string) (map[s
 	}
 
 	var receiptResp *PushReceiptResponse
-	err = json.NewDecoder(resp.Body).Decode(&receiptResp)
-	if err != nil {
+	if err = c.decodeResponse(resp.Body, &receiptResp); err != nil {
 		return nil, err
 	}
 
 	if receiptResp.Errors != nil {
 		return nil, NewServerError("error fetching receipts", resp, nil, receiptResp.Errors)
 	}
+	if err = c.checkReceipts(receiptResp.Data); err != nil {
+		return nil, err
+	}
+	for _, receipt := range receiptResp.Data {
+		if receipt != nil && receipt.ErrorCode() == ErrorMsgRateExceeded {
+			c.recordRateLimited(ctx, "MessageRateExceeded receipt")
+			break
+		}
+	}
 
 	return receiptResp.Data, nil
 }
 
+// post sends a JSON request body to the Expo API
+func (c *Client) post(ctx context.Context, url string, body io.Reader, compressed bool) (*http.Response, error) {
+	reqCtx, info := withCallInfo(ctx)
+	req, err := http.NewRequestWithContext(reqCtx, "POST", url, body)
+	if err != nil {
+		closeBody(body)
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Accept-Encoding", "gzip, deflate")
+
+	if compressed {
+		req.Header.Add("Content-Encoding", "gzip")
+	}
+
+	if token := c.accessToken(ctx); token != "" {
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+
+	if err := c.dumpRequest(req); err != nil {
+		closeBody(body)
+		return nil, err
+	}
+	release, err := c.acquireRequestSlot(ctx)
+	if err != nil {
+		// Do closes the body of every request it is given, but this one never reaches it
+		closeBody(req.Body)
+		return nil, err
+	}
+	start := c.cnf.Clock.Now()
+	resp, err := c.cnf.HttpClient.Do(req)
+	end := c.cnf.Clock.Now()
+	if err != nil {
+		release()
+	} else {
+		resp.Body = &releaseOnClose{ReadCloser: resp.Body, release: release}
+	}
+	labels := map[string]string{"endpoint": path.Base(req.URL.Path), "status": "error"}
+	if resp != nil {
+		labels["status"] = strconv.Itoa(resp.StatusCode)
+	}
+	c.incCounter(ctx, MetricRequests, 1, labels)
+	c.observeDuration(ctx, MetricRequestDuration, end.Sub(start), labels)
+	if err != nil {
+		c.log(ctx, LogWarn, "request failed", map[string]string{"endpoint": labels["endpoint"], "error": err.Error()})
+		return nil, err
+	}
+	info.fill(req, resp, end.Sub(start), end)
+	if c.cnf.OnCall != nil {
+		c.cnf.OnCall(ctx, info)
+	}
+	if err := c.dumpResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// closeBody closes body if it is an io.Closer, such as the pipe of a streamed
+// request body, whose writing goroutine would otherwise block forever
+func closeBody(body io.Reader) {
+	if closer, ok := body.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// acquireRequestSlot waits until fewer than MaxConcurrentRequests requests are
+// in flight. The returned func frees the slot and may be called more than once.
+func (c *Client) acquireRequestSlot(ctx context.Context) (func(), error) {
+	if c.inflight == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.inflight <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-c.inflight })
+	}, nil
+}
+
+// releaseOnClose frees a request slot once the response body is closed, so a
+// request counts as in flight until its response has been read
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *releaseOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
+
 func checkStatus(resp *http.Response) error {
 	if resp.StatusCode >= http.StatusOK && resp.StatusCode <= 299 {
 		return nil
@@ -0,0 +1,744 @@
+package expo_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name     string
+		respond  func(t *testing.T, w http.ResponseWriter, req recordedRequest)
+		wantErr  string
+		validate func(t *testing.T, responses []*expo.MessageResponse)
+	}{
+		{
+			name: "tickets matched to recipients",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				okTickets(t, w, req)
+			},
+			validate: func(t *testing.T, responses []*expo.MessageResponse) {
+				if len(responses) != 3 {
+					t.Fatalf("got %d responses, want 3", len(responses))
+				}
+				for i, response := range responses {
+					if !response.IsOk() || response.ID != fmt.Sprintf("ticket-%d", i) {
+						t.Errorf("response %d = %+v", i, response)
+					}
+					if *response.Token != *testToken(i) {
+						t.Errorf("response %d token = %s, want %s", i, *response.Token, *testToken(i))
+					}
+				}
+				if responses[0].MessageItem != responses[1].MessageItem || responses[1].MessageItem == responses[2].MessageItem {
+					t.Error("responses not matched to their messages")
+				}
+			},
+		},
+		{
+			name: "ticket errors",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]any{
+					{"status": "ok", "id": "ticket-0"},
+					{"status": "error", "message": "not registered", "details": map[string]any{"error": "DeviceNotRegistered"}},
+					{"status": "ok", "id": "ticket-2"},
+				}})
+			},
+			validate: func(t *testing.T, responses []*expo.MessageResponse) {
+				if responses[1].IsOk() || responses[1].ErrorCode() != expo.ErrorMsgDeviceNotRegistered {
+					t.Errorf("response 1 = %+v, want DeviceNotRegistered", responses[1])
+				}
+				if !responses[0].IsOk() || !responses[2].IsOk() {
+					t.Error("other responses should be ok")
+				}
+			},
+		},
+		{
+			name: "errors array",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{"errors": []map[string]any{
+					{"code": "PUSH_TOO_MANY_EXPERIENCE_IDS", "message": "too many experience IDs"},
+				}})
+			},
+			wantErr: "invalid request",
+		},
+		{
+			name: "mismatched response length",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]any{{"status": "ok", "id": "ticket-0"}}})
+			},
+			wantErr: "mismatched response length",
+		},
+		{
+			name: "missing data",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{})
+			},
+			wantErr: "invalid server response",
+		},
+		{
+			name: "client error status",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				http.Error(w, "bad request", http.StatusBadRequest)
+			},
+			wantErr: "invalid response (400",
+		},
+		{
+			name: "malformed body",
+			respond: func(t *testing.T, w http.ResponseWriter, req recordedRequest) {
+				w.Write([]byte(`{"data": [`))
+			},
+			wantErr: "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+				tt.respond(t, w, req)
+			})
+			client, _ := newTestClient(server)
+			msgs := []*expo.Message{
+				{To: []*expo.Token{testToken(0), testToken(1)}, Body: "first"},
+				{To: []*expo.Token{testToken(2)}, Body: "second"},
+			}
+
+			responses, err := client.Publish(context.Background(), msgs)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Publish() error = %v, want %q", err, tt.wantErr)
+				}
+				if n := len(server.Requests()); n != 1 {
+					t.Errorf("sent %d requests, want 1", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish() error = %v", err)
+			}
+			tt.validate(t, responses)
+		})
+	}
+}
+
+func TestPublishRequest(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		okTickets(t, w, req)
+	})
+	client, _ := newTestClient(server, expo.WithAccessToken("secret-token"))
+
+	msg := &expo.Message{To: []*expo.Token{testToken(0)}, Title: "Hello", Body: "World", Data: expo.Data{"key": "value"}}
+	if _, err := client.PublishSingle(context.Background(), msg); err != nil {
+		t.Fatalf("PublishSingle() error = %v", err)
+	}
+
+	req := server.Requests()[0]
+	if req.Path != "/--/api/v2/push/send" {
+		t.Errorf("path = %q", req.Path)
+	}
+	for name, want := range map[string]string{
+		"Authorization": "Bearer secret-token",
+		"Content-Type":  "application/json",
+		"Accept":        "application/json",
+	} {
+		if got := req.Header.Get(name); got != want {
+			t.Errorf("%s header = %q, want %q", name, got, want)
+		}
+	}
+	sent := sentMessages(t, req.Body)
+	if len(sent) != 1 || sent[0]["title"] != "Hello" || sent[0]["body"] != "World" || sent[0]["data"].(map[string]any)["key"] != "value" {
+		t.Errorf("sent %s", req.Body)
+	}
+}
+
+func TestPublishGzip(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		messages  int
+		wantGzip  bool
+	}{
+		{name: "above threshold", threshold: 1024, messages: 50, wantGzip: true},
+		{name: "below threshold", threshold: 1024, messages: 1, wantGzip: false},
+		{name: "always", threshold: 0, messages: 1, wantGzip: true},
+	}
+	for _, tt := range tests {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/streaming=%t", tt.name, streaming), func(t *testing.T) {
+				server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+					okTickets(t, w, req)
+				})
+				client, _ := newTestClient(server, expo.WithGzipThreshold(tt.threshold), expo.WithStreamingBody(streaming))
+
+				responses, err := client.Publish(context.Background(), testMessages(tt.messages))
+				if err != nil {
+					t.Fatalf("Publish() error = %v", err)
+				}
+				if len(responses) != tt.messages {
+					t.Errorf("got %d responses, want %d", len(responses), tt.messages)
+				}
+				// A streamed body's size is not known up front, so it is always compressed
+				wantGzip := tt.wantGzip || streaming
+				req := server.Requests()[0]
+				if gzipped := req.Header.Get("Content-Encoding") == "gzip"; gzipped != wantGzip {
+					t.Errorf("gzipped = %t, want %t", gzipped, wantGzip)
+				}
+				// The server decompressed the body, so it must decode either way
+				if n := recipientCount(t, req.Body); n != tt.messages {
+					t.Errorf("server decoded %d recipients, want %d", n, tt.messages)
+				}
+			})
+		}
+	}
+}
+
+func TestPublishRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		retryAfter string
+		wantWaits  []time.Duration
+		wantCodes  []int
+	}{
+		{name: "429 with Retry-After", statuses: []int{429}, retryAfter: "5", wantWaits: []time.Duration{5 * time.Second}},
+		{name: "503 then success", statuses: []int{503, 503}, wantWaits: []time.Duration{time.Second, 2 * time.Second}},
+		{name: "Retry-After shorter than backoff", statuses: []int{502}, retryAfter: "0", wantWaits: []time.Duration{time.Second}},
+		{name: "Retry-After capped at MaxInterval", statuses: []int{429}, retryAfter: "3600", wantWaits: []time.Duration{30 * time.Second}},
+		{
+			name:      "exhausted",
+			statuses:  []int{500, 502, 503, 504},
+			wantWaits: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+			wantCodes: []int{500, 502, 503, 504},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statuses := slices.Clone(tt.statuses)
+			server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+				if len(statuses) > 0 {
+					status := statuses[0]
+					statuses = statuses[1:]
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					http.Error(w, http.StatusText(status), status)
+					return
+				}
+				okTickets(t, w, req)
+			})
+			client, clock := newTestClient(server)
+
+			responses, err := client.Publish(context.Background(), testMessages(2))
+			if tt.wantCodes != nil {
+				var exhausted *expo.RetryExhaustedError
+				if !errors.As(err, &exhausted) {
+					t.Fatalf("Publish() error = %v, want RetryExhaustedError", err)
+				}
+				if !slices.Equal(exhausted.StatusCodes(), tt.wantCodes) {
+					t.Errorf("status codes = %v, want %v", exhausted.StatusCodes(), tt.wantCodes)
+				}
+			} else if err != nil || len(responses) != 2 {
+				t.Fatalf("Publish() = %d responses, %v", len(responses), err)
+			}
+			if waits := clock.Waits(); !slices.Equal(waits, tt.wantWaits) {
+				t.Errorf("waited %v, want %v", waits, tt.wantWaits)
+			}
+			if n, want := len(server.Requests()), len(tt.wantWaits)+1; n != want {
+				t.Errorf("sent %d requests, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestPublishTooManyMessages(t *testing.T) {
+	toMany := func(n int) *expo.Message {
+		return &expo.Message{To: testTokens(n), Body: "hello"}
+	}
+	tests := []struct {
+		name    string
+		msgs    []*expo.Message
+		wantErr bool
+	}{
+		{name: "100 messages", msgs: testMessages(100)},
+		{name: "101 messages", msgs: testMessages(101), wantErr: true},
+		{name: "100 recipients", msgs: []*expo.Message{toMany(100)}},
+		{name: "5000 recipients", msgs: []*expo.Message{toMany(5000)}, wantErr: true},
+		{name: "recipients across messages", msgs: []*expo.Message{toMany(60), toMany(41)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+				okTickets(t, w, req)
+			})
+			client, _ := newTestClient(server)
+
+			_, err := client.Publish(context.Background(), tt.msgs)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Publish() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), "too many notifications") {
+				t.Fatalf("Publish() error = %v, want too many notifications", err)
+			}
+			if n := len(server.Requests()); n != 0 {
+				t.Errorf("sent %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestPublishDefaultValidation(t *testing.T) {
+	empty, malformed := expo.Token(""), expo.Token("abc")
+	tests := []struct {
+		name    string
+		opts    []expo.Option
+		msg     *expo.Message
+		wantErr string
+	}{
+		{name: "no recipients", msg: &expo.Message{Body: "hello"}, wantErr: "message 0: to: no recipients"},
+		{name: "nil token", msg: &expo.Message{To: []*expo.Token{testToken(0), nil}}, wantErr: "message 0: to[1]: missing push token"},
+		{name: "empty token", msg: &expo.Message{To: []*expo.Token{&empty}}, wantErr: "message 0: to[0]: invalid push token"},
+		{name: "malformed token sent", msg: &expo.Message{To: []*expo.Token{&malformed}, Priority: "urgent"}},
+		{
+			name:    "strict opt-in",
+			opts:    []expo.Option{expo.WithValidation(expo.ValidationStrict)},
+			msg:     &expo.Message{To: []*expo.Token{testToken(0)}, Priority: "urgent"},
+			wantErr: "invalid priority",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+				okTickets(t, w, req)
+			})
+			client, _ := newTestClient(server, tt.opts...)
+
+			_, err := client.Publish(context.Background(), []*expo.Message{tt.msg})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Publish() = %v", err)
+				}
+				if n := len(server.Requests()); n != 1 {
+					t.Errorf("sent %d requests, want 1", n)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Publish() error = %v, want %q", err, tt.wantErr)
+			}
+			if n := len(server.Requests()); n != 0 {
+				t.Errorf("sent %d requests, want none", n)
+			}
+		})
+	}
+}
+
+func TestGetPushReceipts(t *testing.T) {
+	tests := []struct {
+		name     string
+		ids      []string
+		respond  func(w http.ResponseWriter, req recordedRequest)
+		wantErr  string
+		validate func(t *testing.T, receipts map[string]*expo.PushReceipt)
+	}{
+		{
+			name: "receipts",
+			ids:  []string{"ticket-0", "ticket-1", "ticket-2"},
+			respond: func(w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+					"ticket-0": map[string]any{"status": "ok"},
+					"ticket-1": map[string]any{"status": "error", "message": "gone", "details": map[string]any{"error": "DeviceNotRegistered"}},
+				}})
+			},
+			validate: func(t *testing.T, receipts map[string]*expo.PushReceipt) {
+				if len(receipts) != 2 {
+					t.Fatalf("got %d receipts, want 2", len(receipts))
+				}
+				if !receipts["ticket-0"].IsOk() {
+					t.Errorf("ticket-0 = %+v", receipts["ticket-0"])
+				}
+				if receipts["ticket-1"].ErrorCode() != expo.ErrorMsgDeviceNotRegistered {
+					t.Errorf("ticket-1 = %+v", receipts["ticket-1"])
+				}
+				if _, ok := receipts["ticket-2"]; ok {
+					t.Error("ticket-2 has no receipt yet")
+				}
+			},
+		},
+		{
+			name: "errors array",
+			ids:  []string{"ticket-0"},
+			respond: func(w http.ResponseWriter, req recordedRequest) {
+				writeJSON(w, http.StatusOK, map[string]any{"errors": []map[string]any{{"code": "INTERNAL", "message": "oops"}}})
+			},
+			wantErr: "error fetching receipts",
+		},
+		{
+			name: "server error",
+			ids:  []string{"ticket-0"},
+			respond: func(w http.ResponseWriter, req recordedRequest) {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			},
+			wantErr: "giving up after 4 attempts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, tt.respond)
+			client, _ := newTestClient(server)
+
+			receipts, err := client.GetPushReceipts(context.Background(), tt.ids)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetPushReceipts() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetPushReceipts() error = %v", err)
+			}
+			tt.validate(t, receipts)
+
+			req := server.Requests()[0]
+			if req.Path != "/--/api/v2/push/getReceipts" {
+				t.Errorf("path = %q", req.Path)
+			}
+			var body expo.PushReceiptRequest
+			if err := json.Unmarshal(req.Body, &body); err != nil || !slices.Equal(body.IDs, tt.ids) {
+				t.Errorf("sent %s", req.Body)
+			}
+		})
+	}
+}
+
+func TestGetPushReceiptsLimits(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{}})
+	})
+	client, _ := newTestClient(server)
+
+	receipts, err := client.GetPushReceipts(context.Background(), nil)
+	if err != nil || len(receipts) != 0 {
+		t.Errorf("GetPushReceipts(nil) = %v, %v", receipts, err)
+	}
+	ids := make([]string, 1001)
+	if _, err := client.GetPushReceipts(context.Background(), ids); err == nil {
+		t.Error("GetPushReceipts() of 1001 IDs should fail")
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("sent %d requests, want none", n)
+	}
+}
+
+func TestSendPushNotificationsWithReceipts(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		if strings.HasSuffix(req.Path, "/push/send") {
+			writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]any{
+				{"status": "ok", "id": "ticket-a"},
+				{"status": "error", "message": "bad token", "details": map[string]any{"error": "DeviceNotRegistered"}},
+				{"status": "ok", "id": "ticket-c"},
+			}})
+			return
+		}
+		// Receipts come back keyed by ticket ID, in no particular order
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"ticket-c": map[string]any{"status": "error", "message": "too big", "details": map[string]any{"error": "MessageTooBig"}},
+			"ticket-a": map[string]any{"status": "ok"},
+		}})
+	})
+	client, _ := newTestClient(server)
+
+	msgs := []*expo.Message{
+		{To: []*expo.Token{testToken(0), testToken(1)}, Body: "first"},
+		{To: []*expo.Token{testToken(2)}, Body: "second"},
+	}
+	results, err := client.SendPushNotificationsWithReceipts(context.Background(), msgs, time.Second)
+	if err != nil {
+		t.Fatalf("SendPushNotificationsWithReceipts() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+
+	tests := []struct {
+		token      *expo.Token
+		message    *expo.Message
+		ticketID   string
+		successful bool
+		errorCode  string
+	}{
+		{token: testToken(0), message: msgs[0], ticketID: "ticket-a", successful: true},
+		{token: testToken(1), message: msgs[0], errorCode: "DeviceNotRegistered"},
+		{token: testToken(2), message: msgs[1], ticketID: "ticket-c", errorCode: "MessageTooBig"},
+	}
+	for i, want := range tests {
+		result := results[i]
+		if *result.Token != *want.token || result.Message != want.message || result.TicketID != want.ticketID {
+			t.Errorf("result %d = token %s, ticket %q; want token %s, ticket %q", i, *result.Token, result.TicketID, *want.token, want.ticketID)
+		}
+		if result.IsSuccessful() != want.successful || result.ErrorCode() != want.errorCode {
+			t.Errorf("result %d: successful %t, error code %q; want %t, %q", i, result.IsSuccessful(), result.ErrorCode(), want.successful, want.errorCode)
+		}
+	}
+}
+
+func TestSendPushNotificationsWithReceiptsCanceledSinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		if strings.HasSuffix(req.Path, "/push/send") {
+			okTickets(t, w, req)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"ticket-0": map[string]any{"status": "ok"},
+		}})
+	})
+	var sinkErr error
+	var sunk int
+	sink := expo.ResultSinkFunc(func(ctx context.Context, results []*expo.PushResult) error {
+		sinkErr, sunk = ctx.Err(), len(results)
+		return nil
+	})
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	client := expo.NewClient(expo.WithHost(server.URL), expo.WithClock(clock),
+		expo.WithReceiptFetchOnCancel(time.Second), expo.WithResultSink(sink))
+
+	// The caller gives up while the workflow waits for the receipts
+	go func() {
+		clock.BlockUntil(1)
+		cancel()
+	}()
+	results, err := client.SendPushNotificationsWithReceipts(ctx, testMessages(1), time.Minute)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendPushNotificationsWithReceipts() error = %v, want context.Canceled", err)
+	}
+	if len(results) != 1 || results[0].PushReceipt == nil {
+		t.Fatalf("results = %+v, want the receipt fetched on cancel", results)
+	}
+	if sunk != 1 || sinkErr != nil {
+		t.Errorf("sink got %d results with context error %v, want 1 with a live context", sunk, sinkErr)
+	}
+}
+
+func TestSendPushNotificationsWithReceiptsGivesUp(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		if strings.HasSuffix(req.Path, "/push/send") {
+			okTickets(t, w, req)
+			return
+		}
+		// The receipt of ticket-1 never becomes available
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{"ticket-0": map[string]any{"status": "ok"}}})
+	})
+	schedule := &expo.ReceiptSchedule{FirstCheck: 15 * time.Minute, Interval: 15 * time.Minute, GiveUpAfter: time.Hour}
+	client, _ := newTestClient(server, expo.WithReceiptSchedule(schedule))
+
+	results, err := client.SendPushNotificationsWithReceipts(context.Background(), testMessages(2), 0)
+	if err != nil {
+		t.Fatalf("SendPushNotificationsWithReceipts() error = %v", err)
+	}
+	if !results[0].IsSuccessful() {
+		t.Errorf("result 0 = %+v, want successful", results[0])
+	}
+	if !errors.Is(results[1].Error, expo.ErrReceiptUnavailable) {
+		t.Errorf("result 1 error = %v, want %v", results[1].Error, expo.ErrReceiptUnavailable)
+	}
+	if n := len(server.Requests()); n != 5 {
+		t.Errorf("sent %d requests, want 1 publish and 4 receipt checks", n)
+	}
+}
+
+func TestBatchSenderChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		msgs      func() []*expo.Message
+		chunkSize int
+		want      []int
+	}{
+		{name: "messages", msgs: func() []*expo.Message { return testMessages(250) }, want: []int{100, 100, 50}},
+		{name: "smaller chunks", msgs: func() []*expo.Message { return testMessages(25) }, chunkSize: 10, want: []int{10, 10, 5}},
+		{
+			name: "message with too many recipients",
+			msgs: func() []*expo.Message {
+				msg := &expo.Message{Body: "broadcast"}
+				for i := range 250 {
+					msg.To = append(msg.To, testToken(i))
+				}
+				return []*expo.Message{msg}
+			},
+			want: []int{100, 100, 50},
+		},
+		{
+			name: "recipients counted across messages",
+			msgs: func() []*expo.Message {
+				var msgs []*expo.Message
+				for i := range 3 {
+					msgs = append(msgs, &expo.Message{To: []*expo.Token{testToken(3 * i), testToken(3*i + 1), testToken(3*i + 2)}})
+				}
+				return msgs
+			},
+			chunkSize: 5,
+			want:      []int{3, 3, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+				okTickets(t, w, req)
+			})
+			client, _ := newTestClient(server)
+			msgs := tt.msgs()
+
+			cnf := &expo.BatchConfig{ChunkSize: tt.chunkSize}
+			report, err := expo.NewBatchSender(client, cnf).Send(context.Background(), msgs)
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if cnf.ChunkSize != tt.chunkSize || cnf.Concurrency != 0 {
+				t.Errorf("config changed to %+v", *cnf)
+			}
+			var sizes []int
+			for _, req := range server.Requests() {
+				sizes = append(sizes, recipientCount(t, req.Body))
+			}
+			if !slices.Equal(sizes, tt.want) {
+				t.Errorf("request sizes = %v, want %v", sizes, tt.want)
+			}
+
+			// Responses refer to the input messages, also for split ones
+			inputs := make(map[*expo.Message]bool)
+			for _, msg := range msgs {
+				inputs[msg] = true
+			}
+			responses := report.Responses()
+			for _, response := range responses {
+				if !inputs[response.MessageItem] {
+					t.Fatalf("response refers to a message that was not sent: %+v", response.MessageItem)
+				}
+			}
+			if total := recipientTotal(msgs); len(responses) != total {
+				t.Errorf("got %d responses, want %d", len(responses), total)
+			}
+		})
+	}
+}
+
+func recipientTotal(msgs []*expo.Message) int {
+	n := 0
+	for _, msg := range msgs {
+		n += len(msg.To)
+	}
+	return n
+}
+
+func TestStreamedBodyClosedOnEarlyReturn(t *testing.T) {
+	unblock := make(chan struct{})
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		<-unblock
+		okTickets(t, w, req)
+	})
+	client, _ := newTestClient(server, expo.WithStreamingBody(true), expo.WithMaxConcurrentRequests(1))
+
+	// The first call holds the only request slot until the server is unblocked
+	first := make(chan error, 1)
+	go func() {
+		_, err := client.Publish(context.Background(), testMessages(1))
+		first <- err
+	}()
+	for len(server.Requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The second call gives up waiting for a slot; its body must not be left streaming
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Publish(ctx, testMessages(1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Publish() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(unblock)
+	if err := <-first; err != nil {
+		t.Fatalf("first Publish() error = %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for streamingGoroutines() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still streaming request bodies", streamingGoroutines())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// streamingGoroutines counts the goroutines writing streamed request bodies
+func streamingGoroutines() int {
+	buf := make([]byte, 1<<20)
+	return strings.Count(string(buf[:runtime.Stack(buf, true)]), "expo-push-notification.streamBody")
+}
+
+func BenchmarkPublish(b *testing.B) {
+	msgs := testMessages(100)
+	for _, gzip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("gzip=%t", gzip), func(b *testing.B) {
+			client := cannedClient(http.StatusOK, okTicketsBody(len(msgs)), expo.WithGzipEnabled(gzip))
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				if _, err := client.Publish(ctx, msgs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeNonStringDetails(t *testing.T) {
+	ticket := []byte(`{"data":[{"status":"error","message":"slow down","details":{"error":"MessageRateExceeded","retryAfter":5,"limits":{"perSecond":600}}}]}`)
+	receipt := []byte(`{"data":{"ticket-0":{"status":"error","message":"gone","details":{"error":"DeviceNotRegistered","attempt":2}}}}`)
+
+	t.Run("tolerant", func(t *testing.T) {
+		responses, err := cannedClient(http.StatusOK, ticket).Publish(context.Background(), testMessages(1))
+		if err != nil {
+			t.Fatalf("Publish() = %v", err)
+		}
+		details := responses[0].Details
+		if responses[0].ErrorCode() != expo.ErrorMsgRateExceeded || details["retryAfter"] != "5" || details["limits"] != `{"perSecond":600}` {
+			t.Errorf("details = %q", details)
+		}
+
+		receipts, err := cannedClient(http.StatusOK, receipt).GetPushReceipts(context.Background(), []string{"ticket-0"})
+		if err != nil {
+			t.Fatalf("GetPushReceipts() = %v", err)
+		}
+		if r := receipts["ticket-0"]; r.ErrorCode() != expo.ErrorMsgDeviceNotRegistered || r.Details["attempt"] != "2" {
+			t.Errorf("receipt = %+v", r)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		strict := expo.WithDecodeMode(expo.DecodeStrict)
+		_, err := cannedClient(http.StatusOK, ticket, strict).Publish(context.Background(), testMessages(1))
+		if err == nil || !strings.Contains(err.Error(), `detail "limits" of push ticket 0 is not a string`) {
+			t.Errorf("Publish() error = %v, want non-string detail", err)
+		}
+		_, err = cannedClient(http.StatusOK, receipt, strict).GetPushReceipts(context.Background(), []string{"ticket-0"})
+		if err == nil || !strings.Contains(err.Error(), `detail "attempt" of push receipt ticket-0 is not a string`) {
+			t.Errorf("GetPushReceipts() error = %v, want non-string detail", err)
+		}
+	})
+}
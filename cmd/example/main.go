@@ -42,7 +42,7 @@ func basicExample() {
 	// Create client with enhanced configuration
 	client := expo.NewClient(
 		expo.WithAccessToken(accessToken), // Use token from environment
-		expo.WithGzipEnabled(true),        // Enable compression
+		expo.WithGzipThreshold(1024),      // Compress bodies of 1KB and more
 		expo.WithRetryConfig(&expo.RetryConfig{ // Custom retry logic
 			MaxRetries:      3,
 			InitialInterval: 2 * time.Second,
@@ -65,7 +65,7 @@ func basicExample() {
 
 		// iOS-specific fields
 		Subtitle:          "Test Subtitle",
-		InterruptionLevel: "active",
+		InterruptionLevel: expo.InterruptionLevelActive,
 		MutableContent:    true,
 
 		// Android-specific fields
@@ -106,7 +106,7 @@ func completeWorkflowExample() {
 
 	client := expo.NewClient(
 		expo.WithAccessToken(accessToken),
-		expo.WithGzipEnabled(true),
+		expo.WithGzipThreshold(1024),
 	)
 
 	// Create multiple messages
@@ -177,15 +177,14 @@ func errorHandlingExample() {
 		} else if receipt.IsDeviceNotRegistered() {
 			fmt.Println("🚫 Device not registered - remove token from database")
 		} else if receipt.Details != nil {
-			errorType := receipt.Details["error"]
-			switch errorType {
-			case string(expo.ErrorMsgTooBig):
+			switch receipt.ErrorCode() {
+			case expo.ErrorMsgTooBig:
 				fmt.Println("📦 Message too big - reduce payload size")
-			case string(expo.ErrorMsgRateExceeded):
+			case expo.ErrorMsgRateExceeded:
 				fmt.Println("⏱️  Rate exceeded - implement backoff")
-			case string(expo.ErrorMsgMismatchSenderID):
+			case expo.ErrorMsgMismatchSenderID:
 				fmt.Println("🔑 FCM credentials mismatch - check configuration")
-			case string(expo.ErrorMsgInvalidCredentials):
+			case expo.ErrorMsgInvalidCredentials:
 				fmt.Println("🔐 Invalid credentials - regenerate certificates")
 			default:
 				fmt.Printf("❌ Unknown error: %s\n", receipt.Message)
@@ -0,0 +1,149 @@
+// Package main drives an Expo push endpoint at a fixed request rate and
+// reports latency percentiles. By default it targets an in-process fake server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+func main() {
+	host := flag.String("host", "", "Expo host to target (defaults to an in-process fake server)")
+	rps := flag.Int("rps", 50, "publish requests per second")
+	duration := flag.Duration("duration", 10*time.Second, "how long to run")
+	batch := flag.Int("batch", 100, "messages per publish request")
+	latency := flag.Duration("latency", 20*time.Millisecond, "simulated latency of the fake server")
+	gzipThreshold := flag.Int("gzip", -1, "gzip bodies of at least this many bytes (-1 disables)")
+	stream := flag.Bool("stream", false, "stream request bodies")
+	messagesFile := flag.String("messages", "", "JSON array or NDJSON file of messages to send instead of generated ones")
+	flag.Parse()
+
+	if *rps <= 0 || *batch <= 0 {
+		log.Fatal("rps and batch must be positive")
+	}
+
+	opts := []expo.Option{
+		expo.WithStreamingBody(*stream),
+		expo.WithRetryConfig(&expo.RetryConfig{MaxRetries: 0}),
+	}
+	if *gzipThreshold >= 0 {
+		opts = append(opts, expo.WithGzipThreshold(*gzipThreshold))
+	}
+
+	var client *expo.Client
+	if *host == "" {
+		server := expotest.NewServer()
+		defer server.Close()
+		server.SetLatency(*latency)
+		client = server.NewClient(opts...)
+	} else {
+		client = expo.NewClient(append(opts, expo.WithHost(*host))...)
+	}
+
+	msgs, err := loadMessages(*messagesFile, *batch)
+	if err != nil {
+		log.Fatal(err)
+	}
+	*batch = len(msgs)
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		failures  int
+		wg        sync.WaitGroup
+	)
+
+	ctx := context.Background()
+	ticker := time.NewTicker(time.Second / time.Duration(*rps))
+	defer ticker.Stop()
+	deadline := time.After(*duration)
+	start := time.Now()
+
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sent := time.Now()
+				_, err := client.Publish(ctx, msgs)
+				elapsed := time.Since(sent)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failures++
+					return
+				}
+				latencies = append(latencies, elapsed)
+			}()
+		}
+	}
+	wg.Wait()
+	report(latencies, failures, *batch, time.Since(start))
+}
+
+// loadMessages reads messages from path, or generates batch messages if path is empty
+func loadMessages(path string, batch int) ([]*expo.Message, error) {
+	if path == "" {
+		msgs := make([]*expo.Message, batch)
+		for i := range msgs {
+			msgs[i] = &expo.Message{
+				To:    []*expo.Token{expo.MustParseToken(fmt.Sprintf("ExponentPushToken[loadtest-%d]", i))},
+				Title: "Load test",
+				Body:  "This is a load test notification",
+				Data:  expo.Data{"index": fmt.Sprint(i)},
+			}
+		}
+		return msgs, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	msgs, err := expo.DecodeMessages(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) == 0 {
+		return nil, fmt.Errorf("no messages in %s", path)
+	}
+	return msgs, nil
+}
+
+func report(latencies []time.Duration, failures, batch int, elapsed time.Duration) {
+	total := len(latencies) + failures
+	fmt.Printf("requests: %d (%d failed) in %s\n", total, failures, elapsed.Round(time.Millisecond))
+	fmt.Printf("throughput: %.1f req/s, %.1f msg/s\n",
+		float64(total)/elapsed.Seconds(), float64(len(latencies)*batch)/elapsed.Seconds())
+	if len(latencies) == 0 {
+		return
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	for _, p := range []float64{50, 90, 99} {
+		fmt.Printf("p%.0f: %s\n", p, percentile(latencies, p))
+	}
+	fmt.Printf("max: %s\n", latencies[len(latencies)-1])
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted)-1) * p / 100)
+	return sorted[idx]
+}
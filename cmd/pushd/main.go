@@ -0,0 +1,209 @@
+// Package main runs pushd, an HTTP gateway that lets services written in any
+// language send Expo push notifications through one shared, rate limited client.
+//
+// Endpoints:
+//
+//	POST /send       publish a JSON array or NDJSON stream of messages
+//	GET  /receipts   fetch the receipts of the tickets given as ?id= parameters
+//	GET  /healthz    report that the service is up
+//	GET  /metrics    expose client metrics in the Prometheus text format
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// maxReceiptIDs is the number of ticket IDs accepted by one /receipts call
+const maxReceiptIDs = 10000
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	host := flag.String("host", "", "Expo host to send to (defaults to the Expo API)")
+	rps := flag.Float64("rps", 0, "publish requests per second sent to Expo (0 means no limit)")
+	concurrency := flag.Int("concurrency", 8, "publish requests sent to Expo in parallel")
+	adaptive := flag.Bool("adaptive", false, "lower the publish rate when Expo reports rate limiting, and raise it again after")
+	maxBody := flag.Int64("max-body", 10<<20, "largest /send request body accepted, in bytes")
+	flag.Parse()
+
+	// Secrets come from the environment rather than flags, which other users can list
+	accessToken := os.Getenv("EXPO_ACCESS_TOKEN")
+	apiKey := os.Getenv("PUSHD_API_KEY")
+	if apiKey == "" {
+		log.Println("Warning: PUSHD_API_KEY not set, requests are not authenticated")
+	}
+
+	metrics := newPromMetrics()
+	opts := []expo.Option{
+		expo.WithAccessToken(accessToken),
+		expo.WithMetrics(metrics),
+		expo.WithLogger(stdLogger{}),
+	}
+	if *host != "" {
+		opts = append(opts, expo.WithHost(*host))
+	}
+	if *adaptive {
+		opts = append(opts, expo.WithAdaptiveRateLimit(nil))
+	}
+	client, err := expo.NewClientStrict(append(expo.ProfileHighThroughput.Options(), opts...)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sender := expo.NewBatchSender(client, &expo.BatchConfig{
+		ChunkSize:   100,
+		Concurrency: *concurrency,
+		Credentials: []*expo.Credential{{Name: "default", AccessToken: accessToken, RequestsPerSecond: *rps}},
+	})
+
+	s := &server{client: client, sender: sender, apiKey: apiKey, maxBody: *maxBody}
+	mux := http.NewServeMux()
+	mux.Handle("POST /send", s.authorize(http.HandlerFunc(s.send)))
+	mux.Handle("GET /receipts", s.authorize(http.HandlerFunc(s.receipts)))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.Handle("GET /metrics", metrics)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("pushd listening on %s", *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+type server struct {
+	client  *expo.Client
+	sender  *expo.BatchSender
+	apiKey  string
+	maxBody int64
+}
+
+// authorize rejects requests without the API key as bearer token, if one is configured
+func (s *server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.apiKey != "" {
+			key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(key), []byte(s.apiKey)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("missing or invalid API key"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendResponse is the body returned by /send
+type sendResponse struct {
+	// Tickets are the push tickets of the chunks that were sent, in order
+	Tickets []*expo.MessageResponse `json:"tickets"`
+	// Errors describe the chunks that could not be sent
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (s *server) send(w http.ResponseWriter, r *http.Request) {
+	msgs, err := expo.DecodeMessages(http.MaxBytesReader(w, r.Body, s.maxBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if issues := expo.ValidateMessages(msgs); expo.HasErrors(issues) {
+		var problems []string
+		for _, issue := range issues {
+			if issue.Severity == expo.SeverityError {
+				problems = append(problems, issue.Error())
+			}
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string][]string{"issues": problems})
+		return
+	}
+
+	report, err := s.sender.Send(r.Context(), msgs)
+	resp := sendResponse{Tickets: report.Responses()}
+	for _, chunk := range report.Unsent() {
+		if chunk.Err != nil {
+			resp.Errors = append(resp.Errors, chunk.Err.Error())
+		}
+	}
+	status := http.StatusOK
+	if err != nil && len(resp.Tickets) == 0 {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, resp)
+}
+
+// receiptsResponse is the body returned by /receipts
+type receiptsResponse struct {
+	Receipts map[string]*expo.PushReceipt `json:"receipts"`
+	// Pending lists the tickets whose receipts are not available yet
+	Pending []string `json:"pending"`
+}
+
+func (s *server) receipts(w http.ResponseWriter, r *http.Request) {
+	var ids []string
+	for _, value := range r.URL.Query()["id"] {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxReceiptIDs {
+		writeError(w, http.StatusBadRequest, errors.New("between 1 and 10000 id parameters are required"))
+		return
+	}
+
+	receipts, pending, err := s.client.WaitForReceipts(r.Context(), ids, &expo.RetryConfig{MaxRetries: 0})
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, receiptsResponse{Receipts: receipts, Pending: pending})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// stdLogger writes client log entries with the standard logger, skipping debug entries
+type stdLogger struct{}
+
+func (stdLogger) Log(level expo.LogLevel, msg string, fields map[string]string) {
+	if level == expo.LogDebug {
+		return
+	}
+	log.Printf("%s: %s %v", level, msg, fields)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// series identifies a metric by name and formatted labels
+type series struct {
+	name   string
+	labels string
+}
+
+// summary accumulates observed durations
+type summary struct {
+	sum   float64
+	count uint64
+}
+
+// promMetrics is an expo.Metrics keeping counters, gauges and duration
+// summaries in memory and serving them in the Prometheus text format
+type promMetrics struct {
+	mu        sync.Mutex
+	counters  map[series]float64
+	gauges    map[series]float64
+	summaries map[series]*summary
+}
+
+func newPromMetrics() *promMetrics {
+	return &promMetrics{
+		counters:  make(map[series]float64),
+		gauges:    make(map[series]float64),
+		summaries: make(map[series]*summary),
+	}
+}
+
+func (m *promMetrics) IncCounter(name string, value float64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[series{name, formatLabels(labels)}] += value
+}
+
+func (m *promMetrics) SetGauge(name string, value float64, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gauges[series{name, formatLabels(labels)}] = value
+}
+
+func (m *promMetrics) ObserveDuration(name string, d time.Duration, labels map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := series{name, formatLabels(labels)}
+	s := m.summaries[key]
+	if s == nil {
+		s = &summary{}
+		m.summaries[key] = s
+	}
+	s.sum += d.Seconds()
+	s.count++
+}
+
+func (m *promMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	var b strings.Builder
+	typed := make(map[string]bool)
+	for _, key := range sortedSeries(m.counters) {
+		if !typed[key.name] {
+			typed[key.name] = true
+			fmt.Fprintf(&b, "# TYPE %s counter\n", key.name)
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", key.name, key.labels, formatValue(m.counters[key]))
+	}
+	for _, key := range sortedSeries(m.gauges) {
+		if !typed[key.name] {
+			typed[key.name] = true
+			fmt.Fprintf(&b, "# TYPE %s gauge\n", key.name)
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", key.name, key.labels, formatValue(m.gauges[key]))
+	}
+	for _, key := range sortedSeries(m.summaries) {
+		if !typed[key.name] {
+			typed[key.name] = true
+			fmt.Fprintf(&b, "# TYPE %s summary\n", key.name)
+		}
+		s := m.summaries[key]
+		fmt.Fprintf(&b, "%s_sum%s %s\n", key.name, key.labels, formatValue(s.sum))
+		fmt.Fprintf(&b, "%s_count%s %d\n", key.name, key.labels, s.count)
+	}
+	w.Write([]byte(b.String()))
+}
+
+func sortedSeries[V any](m map[series]V) []series {
+	return slices.SortedFunc(maps.Keys(m), func(a, b series) int {
+		if c := strings.Compare(a.name, b.name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.labels, b.labels)
+	})
+}
+
+// formatLabels returns labels as {name="value",...} sorted by name, or "" if there are none
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name+"="+strconv.Quote(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
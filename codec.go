@@ -0,0 +1,65 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+)
+
+// Codec encodes the values that queues and stores persist, such as queued
+// messages and tracked tickets. Implementations must round-trip Message,
+// whose JSON encoding carries Extra fields and ClearBadge, so codecs built on
+// other formats should encode messages through their MarshalJSON.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes values as JSON; it is the default Codec
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// GzipCodec returns a Codec that gzips the encoding of inner, which shrinks
+// large backlogs of similar messages several times over
+func GzipCodec(inner Codec) Codec {
+	return gzipCodec{inner: inner}
+}
+
+type gzipCodec struct {
+	inner Codec
+}
+
+func (c gzipCodec) Marshal(v any) ([]byte, error) {
+	data, err := c.inner.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := gzipInto(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c gzipCodec) Unmarshal(data []byte, v any) error {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return c.inner.Unmarshal(decoded, v)
+}
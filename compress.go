@@ -0,0 +1,30 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"sync"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// shouldCompress reports whether a request body of the given size should be gzipped
+func (c *Client) shouldCompress(size int) bool {
+	return c.cnf.EnableGzip && size >= c.cnf.GzipThreshold
+}
+
+// gzipInto compresses data into dst using a pooled gzip.Writer
+func gzipInto(dst *bytes.Buffer, data []byte) error {
+	gzWriter := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(gzWriter)
+
+	gzWriter.Reset(dst)
+	if _, err := gzWriter.Write(data); err != nil {
+		return err
+	}
+	return gzWriter.Close()
+}
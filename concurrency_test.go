@@ -0,0 +1,235 @@
+package expo_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+// These tests share one client between many goroutines; run them with -race.
+
+// parallel runs fn(i) for i in [0, n) in as many goroutines and waits for them
+func parallel(n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestConcurrentPublishAndReceipts(t *testing.T) {
+	srv := expotest.NewServer()
+	defer srv.Close()
+	srv.FailRequests(http.StatusServiceUnavailable, 5)
+	var dump syncBuffer
+	client := srv.NewClient(
+		expo.WithClock(newInstantClock()),
+		expo.WithGzipThreshold(512),
+		expo.WithDebugDump(&dump),
+		expo.WithRetryBudget(&expo.RetryBudget{MaxRetries: 100}),
+		expo.WithDedupeStore(expo.NewMemoryDedupeStore(), time.Hour),
+	)
+	ctx := context.Background()
+
+	const callers, perCaller = 50, 4
+	ids := make([][]string, callers)
+	errs := make([]error, callers)
+	parallel(callers, func(i int) {
+		msgs := make([]*expo.Message, perCaller)
+		for j := range msgs {
+			msgs[j] = &expo.Message{To: []*expo.Token{testToken(i*perCaller + j)}, Body: fmt.Sprintf("caller %d", i)}
+		}
+		responses, err := client.Publish(ctx, msgs)
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		for j, response := range responses {
+			if response.MessageItem != msgs[j] || !response.IsOk() {
+				errs[i] = fmt.Errorf("response %d = %+v", j, response)
+				return
+			}
+			ids[i] = append(ids[i], response.ID)
+		}
+		receipts, err := client.GetPushReceipts(ctx, ids[i])
+		if err == nil && len(receipts) != perCaller {
+			err = fmt.Errorf("got %d receipts", len(receipts))
+		}
+		errs[i] = err
+	})
+
+	seen := make(map[string]bool)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+		for _, id := range ids[i] {
+			if seen[id] {
+				t.Errorf("ticket %s returned twice", id)
+			}
+			seen[id] = true
+		}
+	}
+	if srv.Messages() != callers*perCaller {
+		t.Errorf("server received %d messages, want %d", srv.Messages(), callers*perCaller)
+	}
+}
+
+func TestConcurrentAutoBatch(t *testing.T) {
+	srv := expotest.NewServer()
+	defer srv.Close()
+	client := srv.NewClient(expo.WithAutoBatch(20*time.Millisecond, 50))
+	ctx := context.Background()
+
+	// Callers sharing a *Message must still get only their own responses
+	shared := &expo.Message{To: []*expo.Token{testToken(-1)}, Body: "shared"}
+	const callers = 200
+	errs := make([]error, callers)
+	parallel(callers, func(i int) {
+		msg := shared
+		if i%2 == 0 {
+			msg = &expo.Message{To: []*expo.Token{testToken(i)}, Body: fmt.Sprintf("caller %d", i)}
+		}
+		responses, err := client.PublishSingle(ctx, msg)
+		switch {
+		case err != nil:
+			errs[i] = err
+		case len(responses) != 1 || responses[0].MessageItem != msg || *responses[0].Token != *msg.To[0]:
+			errs[i] = fmt.Errorf("responses = %+v", responses)
+		}
+	})
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	}
+	if srv.Messages() != callers {
+		t.Errorf("server received %d messages, want %d", srv.Messages(), callers)
+	}
+	if srv.Requests() >= callers/2 {
+		t.Errorf("%d calls were sent in %d requests", callers, srv.Requests())
+	}
+}
+
+func TestConcurrentReceiptTracker(t *testing.T) {
+	srv := expotest.NewServer()
+	defer srv.Close()
+	client := srv.NewClient()
+	tracker := expo.NewReceiptTracker(client, nil)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	results := make(map[string]int)
+	record := func(batch []*expo.PushResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, result := range batch {
+			results[result.TicketID]++
+		}
+	}
+
+	// One goroutine checks while the others publish and track
+	const callers = 40
+	done := make(chan struct{})
+	checked := make(chan error)
+	go func() {
+		for {
+			select {
+			case <-done:
+				close(checked)
+				return
+			default:
+			}
+			batch, err := tracker.Check(ctx)
+			if err != nil {
+				checked <- err
+				return
+			}
+			record(batch)
+		}
+	}()
+	parallel(callers, func(i int) {
+		responses, err := client.Publish(ctx, testMessages(3))
+		if err == nil {
+			err = tracker.Track(ctx, responses)
+		}
+		if err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	})
+	close(done)
+	if err := <-checked; err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	batch, err := tracker.Check(ctx)
+	if err != nil {
+		t.Fatalf("final Check() = %v", err)
+	}
+	record(batch)
+
+	if len(results) != callers*3 {
+		t.Errorf("got results for %d tickets, want %d", len(results), callers*3)
+	}
+	for id, n := range results {
+		if n != 1 {
+			t.Errorf("ticket %s reported %d times", id, n)
+		}
+	}
+}
+
+func TestConcurrentRequestLimit(t *testing.T) {
+	const limit = 3
+	var inflight, peak, requests atomic.Int32
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(5 * time.Millisecond)
+		// Some requests are rate limited, which lowers the adaptive rate
+		if requests.Add(1)%7 == 0 {
+			writeJSON(w, http.StatusTooManyRequests, map[string]any{})
+			return
+		}
+		okTickets(t, w, req)
+	})
+	client, _ := newTestClient(s,
+		expo.WithMaxConcurrentRequests(limit),
+		expo.WithAdaptiveRateLimit(&expo.AdaptiveRateConfig{InitialRate: 1000, MinRate: 100, MaxRate: 2000, Cooldown: time.Millisecond}),
+	)
+	ctx := context.Background()
+
+	parallel(30, func(i int) {
+		if _, err := client.Publish(ctx, testMessages(2)); err != nil {
+			t.Errorf("caller %d: %v", i, err)
+		}
+	})
+	if peak.Load() > limit {
+		t.Errorf("%d requests were in flight, limit is %d", peak.Load(), limit)
+	}
+}
+
+// syncBuffer is an io.Writer safe for concurrent writes
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,103 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ErrUnauthorized is matched by errors reporting that Expo rejected the credentials
+var ErrUnauthorized = errors.New("unauthorized")
+
+type accessTokenKey struct{}
+
+// WithAccessTokenOverride returns a copy of ctx whose requests are authorized
+// with token instead of the client's access token, so one client can send on
+// behalf of several Expo projects. An empty token sends requests without one.
+func WithAccessTokenOverride(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, accessTokenKey{}, token)
+}
+
+// accessToken returns the access token for requests made with ctx
+func (c *Client) accessToken(ctx context.Context) string {
+	if token, ok := ctx.Value(accessTokenKey{}).(string); ok {
+		return token
+	}
+	return c.cnf.AccessToken
+}
+
+// credentialsProbeID is a ticket ID that cannot exist, used to reach the API without side effects
+const credentialsProbeID = "00000000-0000-0000-0000-000000000000"
+
+// CredentialsError is returned by ValidateCredentials when Expo rejects the
+// access token, or requires one that is not configured
+type CredentialsError struct {
+	StatusCode int
+	// Code and Message are the error reported by Expo, if any
+	Code    string
+	Message string
+	// TokenConfigured tells whether the client sends an access token
+	TokenConfigured bool
+}
+
+func (e *CredentialsError) Error() string {
+	detail := e.Message
+	if detail == "" {
+		detail = http.StatusText(e.StatusCode)
+	}
+	if !e.TokenConfigured {
+		return fmt.Sprintf("expo rejected the request (%s): push security is enabled for this project, "+
+			"so configure an access token with WithAccessToken", detail)
+	}
+	return fmt.Sprintf("expo rejected the access token (%s): check that it has not been revoked and "+
+		"belongs to the account owning the project, or create a new one in the Expo dashboard", detail)
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return ErrUnauthorized
+}
+
+// ValidateCredentials checks that Expo accepts the access token used with ctx by
+// fetching the receipt of a ticket that does not exist, which sends no
+// notification. Call it at startup to catch misconfiguration early. Rejected
+// credentials are reported as a *CredentialsError matching ErrUnauthorized.
+func (c *Client) ValidateCredentials(ctx context.Context) error {
+	url := c.endpointURL(c.cnf.ReceiptsEndpoint, "/push/getReceipts")
+	newBody, compressed, release, err := c.prepareBody(&PushReceiptRequest{IDs: []string{credentialsProbeID}})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	resp, err := c.WithRetry(ctx, c.cnf.RetryConfig, func() (*http.Response, error) {
+		return c.post(ctx, url, newBody(), compressed)
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	credErr := &CredentialsError{StatusCode: resp.StatusCode, TokenConfigured: c.accessToken(ctx) != ""}
+	var receiptResp *PushReceiptResponse
+	decodeErr := c.decodeResponse(resp.Body, &receiptResp)
+	if decodeErr == nil && receiptResp != nil {
+		for _, e := range receiptResp.Errors {
+			if e["code"] == string(ErrorUnauthorized) {
+				credErr.Code, credErr.Message = e["code"], e["message"]
+				return credErr
+			}
+		}
+	}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return credErr
+	}
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	if decodeErr != nil && decodeErr != io.EOF {
+		return decodeErr
+	}
+	return nil
+}
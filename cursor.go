@@ -0,0 +1,213 @@
+package expo
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultCursorInterval is how often a ReceiptCursor queries receipts by default
+const defaultCursorInterval = 30 * time.Second
+
+// ReceiptCursorConfig holds configuration for a ReceiptCursor
+type ReceiptCursorConfig struct {
+	// Interval is how often pending ticket IDs are queried, every 30 seconds
+	// if not positive
+	Interval time.Duration
+	// BatchSize is the maximum number of ticket IDs per getReceipts request
+	BatchSize int
+	// Buffer is the capacity of the results channel
+	Buffer int
+}
+
+// DefaultReceiptCursorConfig provides sensible defaults for a ReceiptCursor
+func DefaultReceiptCursorConfig() *ReceiptCursorConfig {
+	return &ReceiptCursorConfig{
+		Interval:  defaultCursorInterval,
+		BatchSize: maxReceiptsPerRequest,
+		Buffer:    maxReceiptsPerRequest,
+	}
+}
+
+// ReceiptCursor collects ticket IDs over time and fetches their receipts on a
+// timer, coalescing the IDs of many small sends into few getReceipts calls.
+// Tickets whose receipt is not available yet are queried again on the next
+// tick until ReceiptRetention has passed, after which they are reported with
+// ErrReceiptExpired.
+type ReceiptCursor struct {
+	client  *Client
+	cnf     *ReceiptCursorConfig
+	results chan *PushResult
+
+	mu      sync.Mutex
+	pending []*Ticket
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewReceiptCursor creates a ReceiptCursor and starts polling.
+// Results must be drained, and Close called to stop polling.
+func NewReceiptCursor(client *Client, cnf *ReceiptCursorConfig) *ReceiptCursor {
+	if cnf == nil {
+		cnf = DefaultReceiptCursorConfig()
+	}
+	// Defaults are applied to a copy, leaving the caller's config as it is
+	c := *cnf
+	// A zero interval would query receipts in a busy loop
+	if c.Interval <= 0 {
+		c.Interval = defaultCursorInterval
+	}
+	if c.BatchSize <= 0 || c.BatchSize > client.maxReceiptBatch() {
+		c.BatchSize = client.maxReceiptBatch()
+	}
+	rc := &ReceiptCursor{
+		client:  client,
+		cnf:     &c,
+		results: make(chan *PushResult, c.Buffer),
+		done:    make(chan struct{}),
+	}
+	rc.wg.Add(1)
+	go rc.loop()
+	return rc
+}
+
+// Add queues ticket IDs for the next query
+func (rc *ReceiptCursor) Add(ids ...string) {
+	now := rc.client.cnf.Clock.Now()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, id := range ids {
+		rc.pending = append(rc.pending, &Ticket{ID: id, CreatedAt: now})
+	}
+}
+
+// AddResponses queues the tickets of the successful responses, keeping their
+// message and token so the results can be traced back to the recipient
+func (rc *ReceiptCursor) AddResponses(responses []*MessageResponse) {
+	now := rc.client.cnf.Clock.Now()
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for _, response := range responses {
+		if response.IsOk() && response.ID != "" {
+			rc.pending = append(rc.pending, &Ticket{
+				ID:        response.ID,
+				Token:     response.Token,
+				Message:   response.MessageItem,
+				Meta:      response.MessageItem.meta(),
+				CreatedAt: now,
+			})
+		}
+	}
+}
+
+// Feed queues every ticket ID received from ids until it is closed or ctx is done
+func (rc *ReceiptCursor) Feed(ctx context.Context, ids <-chan string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id, ok := <-ids:
+			if !ok {
+				return
+			}
+			rc.Add(id)
+		}
+	}
+}
+
+// Results returns the channel on which receipts are delivered. It is closed by Close.
+func (rc *ReceiptCursor) Results() <-chan *PushResult {
+	return rc.results
+}
+
+// Pending returns the number of tickets awaiting their receipt
+func (rc *ReceiptCursor) Pending() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.pending)
+}
+
+// Flush queries all pending tickets immediately
+func (rc *ReceiptCursor) Flush(ctx context.Context) error {
+	rc.mu.Lock()
+	pending := rc.pending
+	rc.pending = nil
+	rc.mu.Unlock()
+
+	now := rc.client.cnf.Clock.Now()
+	var retry []*Ticket
+	var fetchErr error
+	for chunk := range slices.Chunk(pending, rc.cnf.BatchSize) {
+		if fetchErr != nil {
+			retry = append(retry, chunk...)
+			continue
+		}
+		ids := make([]string, len(chunk))
+		for i, ticket := range chunk {
+			ids[i] = ticket.ID
+		}
+		receipts, err := rc.client.GetPushReceipts(ctx, ids)
+		if err != nil {
+			fetchErr = err
+			retry = append(retry, chunk...)
+			continue
+		}
+		for _, ticket := range chunk {
+			if receipt, ok := receipts[ticket.ID]; ok {
+				rc.results <- ticket.result(receipt)
+			} else if ticket.Expired(now) {
+				rc.results <- ticket.result(nil)
+			} else {
+				retry = append(retry, ticket)
+			}
+		}
+	}
+
+	if len(retry) > 0 {
+		rc.mu.Lock()
+		rc.pending = append(retry, rc.pending...)
+		rc.mu.Unlock()
+	}
+	return fetchErr
+}
+
+// Close stops polling, makes a final query for pending tickets and closes the
+// results channel. Tickets still without a receipt are dropped. Calls after
+// the first do nothing and return nil.
+func (rc *ReceiptCursor) Close(ctx context.Context) error {
+	var err error
+	rc.closeOnce.Do(func() {
+		close(rc.done)
+		rc.wg.Wait()
+		err = rc.Flush(ctx)
+		close(rc.results)
+	})
+	return err
+}
+
+func (rc *ReceiptCursor) loop() {
+	defer rc.wg.Done()
+
+	for {
+		select {
+		case <-rc.done:
+			return
+		case <-rc.client.cnf.Clock.After(rc.cnf.Interval):
+			if rc.Pending() == 0 {
+				continue
+			}
+			ctx := context.Background()
+			flush := func() error { return rc.Flush(ctx) }
+			if err := rc.client.safely(ctx, "receipt cursor", flush); err != nil {
+				rc.client.log(ctx, LogWarn, "failed to fetch push receipts", map[string]string{
+					"error":   err.Error(),
+					"pending": strconv.Itoa(rc.Pending()),
+				})
+			}
+		}
+	}
+}
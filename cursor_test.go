@@ -0,0 +1,52 @@
+package expo_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+func TestReceiptCursorDefaultsInterval(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"ticket-0": map[string]any{"status": "ok"},
+		}})
+	})
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	client := expo.NewClient(expo.WithHost(server.URL), expo.WithClock(clock))
+
+	cnf := &expo.ReceiptCursorConfig{Buffer: 1}
+	cursor := expo.NewReceiptCursor(client, cnf)
+	defer cursor.Close(context.Background())
+	if *cnf != (expo.ReceiptCursorConfig{Buffer: 1}) {
+		t.Errorf("config changed to %+v", *cnf)
+	}
+	cursor.Add("ticket-0")
+
+	clock.BlockUntil(1)
+	clock.Advance(29 * time.Second)
+	if n := len(server.Requests()); n != 0 {
+		t.Fatalf("sent %d requests before the default interval, want none", n)
+	}
+	clock.Advance(time.Second)
+	result := <-cursor.Results()
+	if result.TicketID != "ticket-0" || result.PushReceipt == nil || !result.PushReceipt.IsOk() {
+		t.Errorf("result = %+v, want ok receipt for ticket-0", result)
+	}
+}
+
+func TestReceiptCursorCloseTwice(t *testing.T) {
+	server := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {})
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	cursor := expo.NewReceiptCursor(expo.NewClient(expo.WithHost(server.URL), expo.WithClock(clock)), nil)
+
+	for i := range 2 {
+		if err := cursor.Close(context.Background()); err != nil {
+			t.Errorf("Close() %d = %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+)
+
+// CompressedDataSuffix is appended to the key of a Data value compressed by
+// CompressData, so the app knows to base64-decode and gunzip it. The key
+// "order" becomes "order.gz".
+const CompressedDataSuffix = ".gz"
+
+// CompressedValue records the size of a Data value before and after compression
+type CompressedValue struct {
+	Key             string
+	OriginalBytes   int
+	CompressedBytes int
+}
+
+// DataCompressionReport lists the values compressed by CompressData
+type DataCompressionReport struct {
+	Values []CompressedValue
+}
+
+// Saved returns the number of bytes saved by compression, which is negative
+// if the compressed values are larger than the originals
+func (r DataCompressionReport) Saved() int {
+	saved := 0
+	for _, value := range r.Values {
+		saved += value.OriginalBytes - value.CompressedBytes
+	}
+	return saved
+}
+
+// CompressData returns a copy of data in which the values of keys are gzipped,
+// base64-encoded and stored under the key with CompressedDataSuffix appended.
+// Keys missing from data are skipped. Short values may grow when compressed;
+// the report tells how much was saved, so callers can keep the original.
+func CompressData(data Data, keys ...string) (Data, DataCompressionReport, error) {
+	var report DataCompressionReport
+	compressed := maps.Clone(data)
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		if _, exists := data[key+CompressedDataSuffix]; exists {
+			return nil, report, fmt.Errorf("data key %q already exists", key+CompressedDataSuffix)
+		}
+
+		var buf bytes.Buffer
+		if err := gzipInto(&buf, []byte(value)); err != nil {
+			return nil, report, fmt.Errorf("compress data key %q: %w", key, err)
+		}
+		encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+		delete(compressed, key)
+		compressed[key+CompressedDataSuffix] = encoded
+		report.Values = append(report.Values, CompressedValue{
+			Key:             key,
+			OriginalBytes:   len(key) + len(value),
+			CompressedBytes: len(key) + len(CompressedDataSuffix) + len(encoded),
+		})
+	}
+	return compressed, report, nil
+}
+
+// DecompressData reverses CompressData, returning a copy of data in which
+// every value whose key ends in CompressedDataSuffix is decoded and stored
+// under its original key
+func DecompressData(data Data) (Data, error) {
+	decompressed := maps.Clone(data)
+	for key, value := range data {
+		original, ok := strings.CutSuffix(key, CompressedDataSuffix)
+		if !ok {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("decode data key %q: %w", key, err)
+		}
+		gzReader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("decompress data key %q: %w", key, err)
+		}
+		plain, err := io.ReadAll(gzReader)
+		if err != nil {
+			return nil, fmt.Errorf("decompress data key %q: %w", key, err)
+		}
+
+		delete(decompressed, key)
+		decompressed[original] = string(plain)
+	}
+	return decompressed, nil
+}
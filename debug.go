@@ -0,0 +1,89 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const redacted = "[REDACTED]"
+
+// sanitizeHeader returns a copy of h with credentials redacted
+func sanitizeHeader(h http.Header) http.Header {
+	h = h.Clone()
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", "Bearer "+redacted)
+	}
+	return h
+}
+
+// readBody reads and replaces body, returning the raw bytes and their
+// decompressed form for display
+func readBody(body io.ReadCloser, encoding string) (io.ReadCloser, []byte, error) {
+	if body == nil || body == http.NoBody {
+		return body, nil, nil
+	}
+	raw, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	display := raw
+	if encoding == "gzip" {
+		if gzReader, err := gzip.NewReader(bytes.NewReader(raw)); err == nil {
+			if decompressed, err := io.ReadAll(gzReader); err == nil {
+				display = decompressed
+			}
+		}
+	}
+	return io.NopCloser(bytes.NewReader(raw)), display, nil
+}
+
+// dumpRequest writes the request to the debug writer, if one is configured
+func (c *Client) dumpRequest(req *http.Request) error {
+	if c.cnf.DebugDump == nil {
+		return nil
+	}
+
+	body, display, err := readBody(req.Body, req.Header.Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+	req.Body = body
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--> %s %s\n", req.Method, req.URL)
+	sanitizeHeader(req.Header).Write(&buf)
+	fmt.Fprintf(&buf, "\n%s\n", c.redact(req.Context(), string(bytes.TrimSpace(display))))
+	c.writeDump(buf.Bytes())
+	return nil
+}
+
+// dumpResponse writes the response to the debug writer, if one is configured
+func (c *Client) dumpResponse(resp *http.Response) error {
+	if c.cnf.DebugDump == nil {
+		return nil
+	}
+
+	body, display, err := readBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+	resp.Body = body
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<-- %s %s %s\n", resp.Status, resp.Request.Method, resp.Request.URL)
+	resp.Header.Write(&buf)
+	fmt.Fprintf(&buf, "\n%s\n", c.redact(resp.Request.Context(), string(bytes.TrimSpace(display))))
+	c.writeDump(buf.Bytes())
+	return nil
+}
+
+func (c *Client) writeDump(p []byte) {
+	c.dumpMu.Lock()
+	defer c.dumpMu.Unlock()
+	c.cnf.DebugDump.Write(p)
+}
@@ -0,0 +1,188 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+
+	"dezeto/expo-push-notification/internal/payload"
+)
+
+// DecodeMode controls how responses with fields unknown to this package are handled
+type DecodeMode int
+
+const (
+	// DecodeTolerant accepts unknown fields, which remain available in the Raw
+	// field of each ticket and receipt, and details that are not strings
+	DecodeTolerant DecodeMode = iota
+	// DecodeStrict rejects responses containing unknown fields or details
+	// that are not strings
+	DecodeStrict
+)
+
+var (
+	ticketFields = sync.OnceValue(func() map[string]bool {
+		return payload.FieldNames(reflect.TypeOf(MessageResponse{}))
+	})
+	receiptFields = sync.OnceValue(func() map[string]bool {
+		return payload.FieldNames(reflect.TypeOf(PushReceipt{}))
+	})
+)
+
+// UnmarshalJSON decodes a push ticket, keeping a copy of it in Raw
+func (r *MessageResponse) UnmarshalJSON(data []byte) error {
+	type messageResponse MessageResponse
+	v := struct {
+		*messageResponse
+		Details json.RawMessage `json:"details"`
+	}{messageResponse: (*messageResponse)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	details, err := decodeDetails(v.Details)
+	if err != nil {
+		return err
+	}
+	r.Details = details
+	r.Raw = slices.Clone(data)
+	return nil
+}
+
+// UnmarshalJSON decodes a push receipt, keeping a copy of it in Raw
+func (r *PushReceipt) UnmarshalJSON(data []byte) error {
+	type pushReceipt PushReceipt
+	v := struct {
+		*pushReceipt
+		Details json.RawMessage `json:"details"`
+	}{pushReceipt: (*pushReceipt)(r)}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	details, err := decodeDetails(v.Details)
+	if err != nil {
+		return err
+	}
+	r.Details = details
+	r.Raw = slices.Clone(data)
+	return nil
+}
+
+// decodeDetails decodes the details object of a ticket or receipt. Values
+// that are not strings, such as numbers or objects Expo may add, are kept as
+// their JSON text rather than failing the whole response.
+func decodeDetails(raw json.RawMessage) (Data, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return nil, err
+	}
+	details := make(Data, len(members))
+	for name, value := range members {
+		var s string
+		if json.Unmarshal(value, &s) != nil {
+			s = string(value)
+		}
+		details[name] = s
+	}
+	return details, nil
+}
+
+// nonStringDetail returns the first member of the details of the JSON object
+// raw whose value is neither a string nor null
+func nonStringDetail(raw json.RawMessage) (string, error) {
+	var v struct {
+		Details map[string]json.RawMessage `json:"details"`
+	}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	for _, name := range slices.Sorted(maps.Keys(v.Details)) {
+		var s *string
+		if json.Unmarshal(v.Details[name], &s) != nil {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// decodeResponse decodes a response body into v, rejecting unknown top-level
+// fields in strict decode mode
+func (c *Client) decodeResponse(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	if c.cnf.DecodeMode == DecodeStrict {
+		dec.DisallowUnknownFields()
+	}
+	return dec.Decode(v)
+}
+
+// unknownField returns the first member of the JSON object raw that is not in known
+func unknownField(raw json.RawMessage, known map[string]bool) (string, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &members); err != nil {
+		return "", err
+	}
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		if !known[name] {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// checkTickets rejects tickets with unknown fields or details that are not
+// strings in strict decode mode
+func (c *Client) checkTickets(tickets []*MessageResponse) error {
+	if c.cnf.DecodeMode != DecodeStrict {
+		return nil
+	}
+	for i, ticket := range tickets {
+		if ticket == nil {
+			continue
+		}
+		name, err := unknownField(ticket.Raw, ticketFields())
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			return fmt.Errorf("unknown field %q in push ticket %d", name, i)
+		}
+		if name, err = nonStringDetail(ticket.Raw); err != nil {
+			return err
+		} else if name != "" {
+			return fmt.Errorf("detail %q of push ticket %d is not a string", name, i)
+		}
+	}
+	return nil
+}
+
+// checkReceipts rejects receipts with unknown fields or details that are not
+// strings in strict decode mode
+func (c *Client) checkReceipts(receipts map[string]*PushReceipt) error {
+	if c.cnf.DecodeMode != DecodeStrict {
+		return nil
+	}
+	for _, id := range slices.Sorted(maps.Keys(receipts)) {
+		if receipts[id] == nil {
+			continue
+		}
+		name, err := unknownField(receipts[id].Raw, receiptFields())
+		if err != nil {
+			return err
+		}
+		if name != "" {
+			return fmt.Errorf("unknown field %q in push receipt %s", name, id)
+		}
+		if name, err = nonStringDetail(receipts[id].Raw); err != nil {
+			return err
+		} else if name != "" {
+			return fmt.Errorf("detail %q of push receipt %s is not a string", name, id)
+		}
+	}
+	return nil
+}
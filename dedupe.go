@@ -0,0 +1,234 @@
+package expo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MetricDuplicateRecipients counts recipients removed by deduplication
+const MetricDuplicateRecipients = "expo_duplicate_recipients_total"
+
+// DeduplicateRecipients returns copies of msgs without repeated tokens and the
+// number of tokens removed. Duplicates are always removed within a message;
+// if acrossBatch is set, a token is also removed from every message after the
+// first one addressing it. The result is aligned with msgs, so a message may be
+// left without recipients, and nil messages stay nil. The input is not modified.
+func DeduplicateRecipients(msgs []*Message, acrossBatch bool) ([]*Message, int) {
+	deduped := make([]*Message, len(msgs))
+	var removed int
+	batchSeen := make(map[Token]bool)
+
+	for i, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		msgCopy := *msg
+		msgCopy.To = make([]*Token, 0, len(msg.To))
+		seen := make(map[Token]bool, len(msg.To))
+		for _, token := range msg.To {
+			if token == nil {
+				msgCopy.To = append(msgCopy.To, token)
+				continue
+			}
+			if seen[*token] || (acrossBatch && batchSeen[*token]) {
+				removed++
+				continue
+			}
+			seen[*token] = true
+			msgCopy.To = append(msgCopy.To, token)
+		}
+		if acrossBatch {
+			for token := range seen {
+				batchSeen[token] = true
+			}
+		}
+		deduped[i] = &msgCopy
+	}
+
+	return deduped, removed
+}
+
+// DedupeStore records which notifications have been sent, so that a message
+// published again (e.g. by another instance, or after a crash) is not
+// delivered twice to the same recipient
+type DedupeStore interface {
+	// Reserve records the keys not seen within ttl, which is positive, and
+	// returns them in the order given; keys already recorded are left out of
+	// the result. A key repeated in keys is reserved by its first occurrence
+	// only, so it appears in the result at most once.
+	Reserve(ctx context.Context, keys []string, ttl time.Duration) ([]string, error)
+	// Release forgets keys, e.g. because sending them failed
+	Release(ctx context.Context, keys []string) error
+}
+
+// MemoryDedupeStore is a DedupeStore kept in memory
+type MemoryDedupeStore struct {
+	// Clock decides when reservations expire; nil uses SystemClock
+	Clock Clock
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+// NewMemoryDedupeStore creates an empty MemoryDedupeStore
+func NewMemoryDedupeStore() *MemoryDedupeStore {
+	return &MemoryDedupeStore{expires: make(map[string]time.Time)}
+}
+
+func (s *MemoryDedupeStore) Reserve(ctx context.Context, keys []string, ttl time.Duration) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clock := s.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	now := clock.Now()
+	var reserved []string
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if expires, ok := s.expires[key]; seen[key] || (ok && now.Before(expires)) {
+			continue
+		}
+		seen[key] = true
+		s.expires[key] = now.Add(ttl)
+		reserved = append(reserved, key)
+	}
+	return reserved, nil
+}
+
+func (s *MemoryDedupeStore) Release(ctx context.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.expires, key)
+	}
+	return nil
+}
+
+// DedupeKey identifies the notification msg sends to token: the same content
+// sent to the same recipient always produces the same key
+func DedupeKey(msg *Message, token *Token) (string, error) {
+	msgCopy := *msg
+	msgCopy.To = []*Token{token}
+	payload, err := msgCopy.MarshalExpoJSON()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// reserveRecipients removes from msgs, in place, the recipients the DedupeStore
+// has already seen, and returns the keys reserved for the remaining ones. A
+// notification repeated within msgs is only sent once, as the store reserves
+// its key once.
+func (c *Client) reserveRecipients(ctx context.Context, msgs []*Message) ([]string, error) {
+	var keys []string
+	for _, msg := range msgs {
+		for _, token := range msg.To {
+			if token == nil {
+				continue
+			}
+			key, err := DedupeKey(msg, token)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	reserved, err := c.cnf.DedupeStore.Reserve(ctx, keys, c.cnf.DedupeTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve recipients: %w", err)
+	}
+	isReserved := make(map[string]bool, len(reserved))
+	for _, key := range reserved {
+		isReserved[key] = true
+	}
+
+	var skipped int
+	i := 0
+	for _, msg := range msgs {
+		to := make([]*Token, 0, len(msg.To))
+		for _, token := range msg.To {
+			if token != nil {
+				key := keys[i]
+				i++
+				if !isReserved[key] {
+					skipped++
+					continue
+				}
+				delete(isReserved, key)
+			}
+			to = append(to, token)
+		}
+		msg.To = to
+	}
+
+	if skipped > 0 {
+		c.incCounter(ctx, MetricDuplicateRecipients, float64(skipped), map[string]string{"source": "store"})
+		c.log(ctx, LogDebug, "skipped recipients already sent", map[string]string{"count": strconv.Itoa(skipped)})
+	}
+	return reserved, nil
+}
+
+// publishDeduplicated publishes msgs without duplicate recipients, mapping the
+// responses back to the caller's messages. Tokens are only removed across
+// messages if acrossBatch is set as well as DedupeAcrossBatch.
+func (c *Client) publishDeduplicated(ctx context.Context, msgs []*Message, acrossBatch bool) ([]*MessageResponse, error) {
+	deduped, removed := DeduplicateRecipients(msgs, c.cnf.DedupeRecipients && c.cnf.DedupeAcrossBatch && acrossBatch)
+	if !c.cnf.DedupeRecipients {
+		// Only the DedupeStore applies; keep repeated tokens
+		for i, msg := range deduped {
+			msg.To = slices.Clone(msgs[i].To)
+		}
+		removed = 0
+	}
+	if removed > 0 {
+		c.incCounter(ctx, MetricDuplicateRecipients, float64(removed), map[string]string{"source": "batch"})
+		c.log(ctx, LogDebug, "removed duplicate recipients", map[string]string{"count": strconv.Itoa(removed)})
+	}
+
+	var reserved []string
+	if c.cnf.DedupeStore != nil {
+		var err error
+		if reserved, err = c.reserveRecipients(ctx, deduped); err != nil {
+			return nil, err
+		}
+	}
+
+	originals := make(map[*Message]*Message, len(msgs))
+	sendable := make([]*Message, 0, len(deduped))
+	for i, msg := range deduped {
+		// Messages whose recipients were all sent before are dropped
+		if len(msg.To) == 0 && len(msgs[i].To) > 0 {
+			continue
+		}
+		originals[msg] = msgs[i]
+		sendable = append(sendable, msg)
+	}
+	if len(sendable) == 0 {
+		return nil, nil
+	}
+
+	responses, err := c.publishValidated(ctx, sendable)
+	if err != nil {
+		if len(reserved) > 0 {
+			c.cnf.DedupeStore.Release(ctx, reserved)
+		}
+		return nil, err
+	}
+	for _, response := range responses {
+		if original, ok := originals[response.MessageItem]; ok {
+			response.MessageItem = original
+		}
+	}
+	return responses, nil
+}
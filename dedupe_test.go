@@ -0,0 +1,57 @@
+package expo_test
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+func TestMemoryDedupeStoreExpires(t *testing.T) {
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	store := expo.NewMemoryDedupeStore()
+	store.Clock = clock
+	ctx := context.Background()
+
+	reserve := func(want ...string) {
+		t.Helper()
+		reserved, err := store.Reserve(ctx, []string{"a"}, time.Minute)
+		if err != nil || !slices.Equal(reserved, want) {
+			t.Errorf("Reserve() = %v, %v, want %v", reserved, err, want)
+		}
+	}
+	reserve("a")
+	clock.Advance(59 * time.Second)
+	reserve()
+	clock.Advance(time.Second)
+	reserve("a")
+}
+
+func TestMemoryDedupeStore(t *testing.T) {
+	expotest.TestDedupeStore(t, func(t *testing.T) expo.DedupeStore {
+		return expo.NewMemoryDedupeStore()
+	})
+}
+
+func TestDedupeStoreRepeatedRecipient(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	client, _ := newTestClient(s, expo.WithDedupeStore(expo.NewMemoryDedupeStore(), time.Hour))
+
+	responses, err := client.Publish(context.Background(), []*expo.Message{
+		{To: []*expo.Token{testToken(0), testToken(0)}, Body: "hello"},
+		{To: []*expo.Token{testToken(0)}, Body: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Publish() = %v", err)
+	}
+	if len(responses) != 1 {
+		t.Errorf("got %d responses, want 1", len(responses))
+	}
+	if reqs := s.Requests(); len(reqs) != 1 || recipientCount(t, reqs[0].Body) != 1 {
+		t.Errorf("sent %d requests, want one to 1 recipient", len(reqs))
+	}
+}
@@ -0,0 +1,520 @@
+package expo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// MetricQueueDropped counts messages removed from a DurableQueue without being sent, by reason
+	MetricQueueDropped = "expo_queue_dropped_total"
+	// MetricQueueFlushed counts messages sent from a DurableQueue
+	MetricQueueFlushed = "expo_queue_flushed_total"
+)
+
+var (
+	// ErrQueued is matched by errors of DurableQueue calls whose messages were
+	// stored to be sent later instead of being sent now
+	ErrQueued = errors.New("messages queued for later delivery")
+	// ErrQueueFull is matched by errors of DurableQueue calls whose messages
+	// could not be sent nor stored because the queue is full
+	ErrQueueFull = errors.New("push queue is full")
+	// ErrDeferred is matched by errors of DurableQueue calls whose messages
+	// were stored to be sent once their DeliveryWindow opens
+	ErrDeferred = errors.New("messages deferred until their delivery window opens")
+)
+
+// DurableQueueConfig holds configuration for a DurableQueue
+type DurableQueueConfig struct {
+	// Path is the file messages are spilled to; it is created if missing
+	Path string
+	// MaxMessages is the number of messages the queue holds
+	MaxMessages int
+	// TTL is how long a message may wait; older messages are dropped
+	TTL time.Duration
+	// FlushInterval is how often sending the queued messages is attempted
+	FlushInterval time.Duration
+	// OnFlush, if set, receives the push tickets of queued messages once sent
+	OnFlush func(responses []*MessageResponse)
+	// Codec, if set, encodes the whole file instead of one JSON message per line.
+	// Changing it requires the queue to be empty, as files are not converted.
+	Codec Codec
+	// DeliveryWindow, if set, holds back messages outside of their recipients'
+	// hours: they are stored and sent once the window opens in their time zone
+	DeliveryWindow *DeliveryWindow
+}
+
+// DefaultDurableQueueConfig provides sensible defaults for a DurableQueue spilling to path
+func DefaultDurableQueueConfig(path string) *DurableQueueConfig {
+	return &DurableQueueConfig{
+		Path:          path,
+		MaxMessages:   10000,
+		TTL:           24 * time.Hour,
+		FlushInterval: 30 * time.Second,
+	}
+}
+
+// queuedMessage is a message waiting in a DurableQueue, as stored on disk
+type queuedMessage struct {
+	EnqueuedAt time.Time `json:"enqueuedAt"`
+	// SendAt is when a message deferred by the DeliveryWindow may be sent
+	SendAt  time.Time         `json:"sendAt"`
+	Message *Message          `json:"message"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+// DurableQueue wraps a Client so that messages which cannot be sent because
+// Expo or the network is unavailable are stored on disk and sent, in order,
+// once connectivity returns. While messages are queued, new messages are
+// queued behind them. The whole file is rewritten on every change, so keep
+// MaxMessages moderate. Receipt calls are passed to the client unchanged.
+//
+// With a DeliveryWindow, messages outside of it are stored until it opens,
+// and sent then whether or not earlier messages are still queued.
+type DurableQueue struct {
+	client *Client
+	cnf    *DurableQueueConfig
+
+	mu      sync.Mutex
+	entries []*queuedMessage
+	flushMu sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Ensure DurableQueue implements PushClient interface
+var _ PushClient = (*DurableQueue)(nil)
+
+// NewDurableQueue creates a DurableQueue, loads the messages left in its
+// file by a previous run and starts flushing them. Call Close to stop.
+func NewDurableQueue(client *Client, cnf *DurableQueueConfig) (*DurableQueue, error) {
+	if cnf == nil || cnf.Path == "" {
+		return nil, errors.New("durable queue requires a path")
+	}
+	// Defaults are applied to a copy, leaving the caller's config as it is
+	c := *cnf
+	defaults := DefaultDurableQueueConfig(c.Path)
+	if c.MaxMessages <= 0 {
+		c.MaxMessages = defaults.MaxMessages
+	}
+	if c.TTL <= 0 {
+		c.TTL = defaults.TTL
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaults.FlushInterval
+	}
+
+	q := &DurableQueue{client: client, cnf: &c, done: make(chan struct{})}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	q.wg.Add(1)
+	go q.loop()
+	return q, nil
+}
+
+// PublishSingle sends a single push notification, or queues it
+func (q *DurableQueue) PublishSingle(ctx context.Context, msg *Message) ([]*MessageResponse, error) {
+	return q.Publish(ctx, []*Message{msg})
+}
+
+// Publish sends msgs through the client. If Expo or the network is
+// unavailable, or earlier messages are still queued, msgs are queued and the
+// returned error matches ErrQueued; their tickets are passed to OnFlush once sent.
+//
+// With a DeliveryWindow, messages outside of it are stored until it opens and
+// the error matches ErrDeferred; the responses are those of the other
+// messages, in order. Messages whose time zone cannot be loaded fail the call
+// before anything is sent.
+func (q *DurableQueue) Publish(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	if q.cnf.DeliveryWindow == nil {
+		return q.publish(ctx, msgs)
+	}
+
+	if err := q.check(msgs); err != nil {
+		return nil, err
+	}
+	due, deferred, err := q.cnf.DeliveryWindow.Partition(msgs, q.client.cnf.Clock.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply delivery window: %w", err)
+	}
+	var responses []*MessageResponse
+	if len(due) > 0 {
+		responses, err = q.publish(ctx, due)
+		if err != nil && !errors.Is(err, ErrQueued) {
+			return responses, err
+		}
+	}
+	if len(deferred) == 0 {
+		return responses, err
+	}
+	if deferErr := q.deferUntilOpen(deferred); deferErr != nil {
+		return responses, errors.Join(err, deferErr)
+	}
+	return responses, errors.Join(err, fmt.Errorf("%w: %d of %d messages", ErrDeferred, len(deferred), len(msgs)))
+}
+
+// publish sends msgs, or queues them behind the messages already due
+func (q *DurableQueue) publish(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	if len(q.due(q.client.cnf.Clock.Now(), 1)) == 0 {
+		responses, err := q.client.Publish(ctx, msgs)
+		if err == nil || !isOutage(err) {
+			return responses, err
+		}
+		return nil, q.enqueue(msgs, err)
+	}
+
+	if err := q.check(msgs); err != nil {
+		return nil, err
+	}
+	return nil, q.enqueue(msgs, errors.New("earlier messages are still queued"))
+}
+
+// PublishToUsers sends each user of userIDs a copy of msg addressed to all of
+// their tokens, like Client.PublishToUsers, but through Publish: the messages
+// are queued during outages and deferred to each user's DeliveryWindow in the
+// time zone given by a LocationResolver. Users without tokens are skipped.
+func (q *DurableQueue) PublishToUsers(ctx context.Context, userIDs []string, msg *Message) ([]*MessageResponse, error) {
+	msgs, err := q.client.userMessages(ctx, userIDs, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []*MessageResponse
+	var errs []error
+	var parts []*Message
+	for _, msg := range msgs {
+		parts = append(parts, SplitRecipients(msg, q.client.maxBatchSize())...)
+	}
+	for _, chunk := range chunkByWeight(parts, q.client.maxBatchSize()) {
+		chunkResponses, err := q.Publish(ctx, chunk)
+		responses = append(responses, chunkResponses...)
+		if err != nil && !errors.Is(err, ErrQueued) && !errors.Is(err, ErrDeferred) {
+			return responses, err
+		}
+		errs = append(errs, err)
+	}
+	return responses, errors.Join(errs...)
+}
+
+// check rejects messages that can never be sent now rather than when flushed
+func (q *DurableQueue) check(msgs []*Message) error {
+	if err := q.client.checkNil(msgs); err != nil {
+		return err
+	}
+	for i, msg := range msgs {
+		if len(msg.To) > q.client.maxBatchSize() {
+			return fmt.Errorf("message %d has %d recipients (maximum is %d per request); split it with SplitRecipients", i, len(msg.To), q.client.maxBatchSize())
+		}
+	}
+	return q.client.rejectInvalid(msgs)
+}
+
+// GetPushReceipts fetches push receipts through the client
+func (q *DurableQueue) GetPushReceipts(ctx context.Context, ticketIDs []string) (map[string]*PushReceipt, error) {
+	return q.client.GetPushReceipts(ctx, ticketIDs)
+}
+
+// SendPushNotificationsWithReceipts runs the workflow through the client; its messages are never queued
+func (q *DurableQueue) SendPushNotificationsWithReceipts(ctx context.Context, messages []*Message, receiptDelay time.Duration) ([]*PushResult, error) {
+	return q.client.SendPushNotificationsWithReceipts(ctx, messages, receiptDelay)
+}
+
+// Len returns the number of queued messages
+func (q *DurableQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Flush drops expired messages and sends the queued messages in order until
+// none is due or Expo is still unavailable, in which case the error is
+// returned. Messages Expo rejects for other reasons are logged and dropped.
+// Deferred messages are left in the queue until their delivery window opens.
+func (q *DurableQueue) Flush(ctx context.Context) error {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	if err := q.expire(ctx); err != nil {
+		return err
+	}
+	for {
+		chunk := q.due(q.client.cnf.Clock.Now(), q.client.maxBatchSize())
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		msgs := make([]*Message, len(chunk))
+		for i, entry := range chunk {
+			msgs[i] = entry.Message
+		}
+		responses, err := q.client.Publish(ctx, msgs)
+		switch {
+		case err != nil && (isOutage(err) || ctx.Err() != nil):
+			return err
+		case err != nil:
+			q.client.log(ctx, LogError, "dropping queued messages rejected by expo", map[string]string{
+				"count": strconv.Itoa(len(msgs)),
+				"error": err.Error(),
+			})
+			q.client.incCounter(ctx, MetricQueueDropped, float64(len(msgs)), map[string]string{"reason": "rejected"})
+		default:
+			q.client.incCounter(ctx, MetricQueueFlushed, float64(len(msgs)), nil)
+			if q.cnf.OnFlush != nil {
+				q.cnf.OnFlush(responses)
+			}
+		}
+
+		// Only Flush removes entries, and flushes do not overlap, so chunk is still queued
+		sent := make(map[*queuedMessage]bool, len(chunk))
+		for _, entry := range chunk {
+			sent[entry] = true
+		}
+		q.mu.Lock()
+		q.entries = slices.DeleteFunc(q.entries, func(entry *queuedMessage) bool { return sent[entry] })
+		err = q.persist()
+		q.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Close stops flushing. Queued messages stay on disk for the next NewDurableQueue.
+// Calls after the first do nothing.
+func (q *DurableQueue) Close() error {
+	q.closeOnce.Do(func() {
+		close(q.done)
+		q.wg.Wait()
+	})
+	return nil
+}
+
+func (q *DurableQueue) loop() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-q.client.cnf.Clock.After(q.cnf.FlushInterval):
+			if q.Len() == 0 {
+				continue
+			}
+			ctx := context.Background()
+			flush := func() error { return q.Flush(ctx) }
+			if err := q.client.safely(ctx, "durable queue", flush); err != nil {
+				q.client.log(ctx, LogWarn, "failed to send queued push notifications", map[string]string{
+					"error":  err.Error(),
+					"queued": strconv.Itoa(q.Len()),
+				})
+			}
+		}
+	}
+}
+
+// enqueue stores msgs at the back of the queue; cause is why they were not sent
+func (q *DurableQueue) enqueue(msgs []*Message, cause error) error {
+	now := q.client.cnf.Clock.Now()
+	entries := make([]*queuedMessage, len(msgs))
+	for i, msg := range msgs {
+		msgCopy := *msg
+		entries[i] = &queuedMessage{EnqueuedAt: now, Message: &msgCopy, Meta: msg.meta()}
+	}
+	if err := q.store(entries); err != nil {
+		return fmt.Errorf("%w: %w", err, cause)
+	}
+	return fmt.Errorf("%w: %w", ErrQueued, cause)
+}
+
+// deferUntilOpen stores the messages held back by the delivery window, to be
+// sent by Flush once it opens
+func (q *DurableQueue) deferUntilOpen(deferred []DeferredMessage) error {
+	now := q.client.cnf.Clock.Now()
+	entries := make([]*queuedMessage, len(deferred))
+	for i, d := range deferred {
+		msgCopy := *d.Message
+		entries[i] = &queuedMessage{EnqueuedAt: now, SendAt: d.SendAt, Message: &msgCopy, Meta: d.Message.meta()}
+	}
+	if err := q.store(entries); err != nil {
+		return fmt.Errorf("failed to defer messages: %w", err)
+	}
+	return nil
+}
+
+// store appends entries to the queue and persists it
+func (q *DurableQueue) store(entries []*queuedMessage) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.entries)+len(entries) > q.cnf.MaxMessages {
+		return ErrQueueFull
+	}
+	q.entries = append(q.entries, entries...)
+	if err := q.persist(); err != nil {
+		q.entries = q.entries[:len(q.entries)-len(entries)]
+		return err
+	}
+	return nil
+}
+
+// due returns the entries that may be sent at now, in queue order, up to
+// limit recipients as counted by requestWeight but at least one entry
+func (q *DurableQueue) due(now time.Time, limit int) []*queuedMessage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []*queuedMessage
+	weight := 0
+	for _, entry := range q.entries {
+		if entry.SendAt.After(now) {
+			continue
+		}
+		w := requestWeight([]*Message{entry.Message})
+		if len(due) > 0 && weight+w > limit {
+			break
+		}
+		due = append(due, entry)
+		weight += w
+	}
+	return due
+}
+
+// expire drops the messages that have waited longer than the TTL, counted
+// for deferred messages from when their delivery window opened
+func (q *DurableQueue) expire(ctx context.Context) error {
+	now := q.client.cnf.Clock.Now()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.entries[:0:0]
+	for _, entry := range q.entries {
+		waitingSince := entry.EnqueuedAt
+		if entry.SendAt.After(waitingSince) {
+			waitingSince = entry.SendAt
+		}
+		if now.Sub(waitingSince) <= q.cnf.TTL {
+			kept = append(kept, entry)
+		}
+	}
+	expired := len(q.entries) - len(kept)
+	if expired == 0 {
+		return nil
+	}
+	q.entries = kept
+	q.client.log(ctx, LogWarn, "dropping expired queued messages", map[string]string{"count": strconv.Itoa(expired)})
+	q.client.incCounter(ctx, MetricQueueDropped, float64(expired), map[string]string{"reason": "expired"})
+	return q.persist()
+}
+
+// load reads the messages stored in the queue file, if it exists
+func (q *DurableQueue) load() error {
+	f, err := os.Open(q.cnf.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open push queue: %w", err)
+	}
+	defer f.Close()
+
+	var entries []*queuedMessage
+	if q.cnf.Codec != nil {
+		data, err := io.ReadAll(f)
+		if err == nil && len(data) > 0 {
+			err = q.cnf.Codec.Unmarshal(data, &entries)
+		}
+		if err != nil {
+			return fmt.Errorf("read push queue: %w", err)
+		}
+	} else {
+		dec := json.NewDecoder(bufio.NewReader(f))
+		for dec.More() {
+			entry := &queuedMessage{}
+			if err := dec.Decode(entry); err != nil {
+				return fmt.Errorf("read push queue: %w", err)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	for _, entry := range entries {
+		if entry == nil || entry.Message == nil {
+			continue
+		}
+		entry.Message.Meta = entry.Meta
+		q.entries = append(q.entries, entry)
+	}
+	return nil
+}
+
+// persist replaces the queue file with the current entries; q.mu must be held
+func (q *DurableQueue) persist() error {
+	tmp, err := os.CreateTemp(filepath.Dir(q.cnf.Path), filepath.Base(q.cnf.Path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write push queue: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	err = q.encode(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), q.cnf.Path)
+	}
+	if err != nil {
+		return fmt.Errorf("write push queue: %w", err)
+	}
+	return nil
+}
+
+// encode writes the entries with the configured codec, or as one JSON object per line
+func (q *DurableQueue) encode(w io.Writer) error {
+	if q.cnf.Codec != nil {
+		data, err := q.cnf.Codec.Marshal(q.entries)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	enc := json.NewEncoder(w)
+	for _, entry := range q.entries {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isOutage reports whether err means Expo or the network is unavailable, so
+// the request may succeed later
+func isOutage(err error) bool {
+	switch ClassifyError(err) {
+	case ErrorClassTemporary:
+		return true
+	case ErrorClassHTTP:
+		var serverErr *ServerError
+		errors.As(err, &serverErr)
+		status := serverErr.Response.StatusCode
+		return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+	}
+	return false
+}
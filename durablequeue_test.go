@@ -0,0 +1,216 @@
+package expo_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+// newWindowQueue returns a DurableQueue of a client of s sending between 09:00
+// and 21:00 UTC, at 03:04 UTC on its clock. It never flushes on its own.
+func newWindowQueue(t *testing.T, s *apiServer, opts ...expo.Option) (*expo.DurableQueue, *expotest.FakeClock) {
+	t.Helper()
+	return newTestQueue(t, s, &expo.DeliveryWindow{Start: 9 * time.Hour, End: 21 * time.Hour}, opts...)
+}
+
+// newTestQueue returns a DurableQueue of a client of s with the given delivery
+// window, at 03:04 UTC on its clock. It never flushes on its own.
+func newTestQueue(t *testing.T, s *apiServer, window *expo.DeliveryWindow, opts ...expo.Option) (*expo.DurableQueue, *expotest.FakeClock) {
+	t.Helper()
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	opts = append([]expo.Option{expo.WithHost(s.URL), expo.WithClock(clock)}, opts...)
+	cnf := expo.DefaultDurableQueueConfig(filepath.Join(t.TempDir(), "queue"))
+	cnf.FlushInterval = 1000 * time.Hour
+	cnf.DeliveryWindow = window
+	queue, err := expo.NewDurableQueue(expo.NewClient(opts...), cnf)
+	if err != nil {
+		t.Fatalf("NewDurableQueue() = %v", err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	return queue, clock
+}
+
+func TestDurableQueueDeliveryWindow(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	queue, clock := newWindowQueue(t, s)
+	ctx := context.Background()
+
+	msgs := testMessages(3)
+	msgs[1].Meta = map[string]string{expo.MetaTimeZone: "Asia/Tokyo"}
+	msgs[2].Meta = map[string]string{expo.MetaTimeZone: "America/New_York"}
+
+	// 03:04 UTC is 12:04 in Tokyo and 22:04 the day before in New York
+	responses, err := queue.Publish(ctx, msgs)
+	if !errors.Is(err, expo.ErrDeferred) {
+		t.Fatalf("Publish() = %v, want %v", err, expo.ErrDeferred)
+	}
+	if len(responses) != 1 || len(s.Requests()) != 1 || sentMessages(t, s.Requests()[0].Body)[0]["body"] != "message 1" {
+		t.Fatalf("Publish() sent %d requests with %d responses", len(s.Requests()), len(responses))
+	}
+	if queue.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", queue.Len())
+	}
+
+	// Deferred messages do not hold back new messages that are due
+	if _, err := queue.Publish(ctx, []*expo.Message{msgs[1]}); err != nil {
+		t.Fatalf("Publish() of a due message = %v", err)
+	}
+
+	flushes := []struct {
+		advance time.Duration
+		want    string
+	}{
+		{advance: 0},
+		{advance: 6 * time.Hour, want: "message 0"},
+		{advance: 5 * time.Hour, want: "message 2"},
+	}
+	for _, flush := range flushes {
+		clock.Advance(flush.advance)
+		before := len(s.Requests())
+		if err := queue.Flush(ctx); err != nil {
+			t.Fatalf("Flush() at %s = %v", clock.Now(), err)
+		}
+		requests := s.Requests()[before:]
+		if flush.want == "" {
+			if len(requests) != 0 {
+				t.Errorf("Flush() at %s sent %d requests", clock.Now(), len(requests))
+			}
+			continue
+		}
+		if len(requests) != 1 || sentMessages(t, requests[0].Body)[0]["body"] != flush.want {
+			t.Errorf("Flush() at %s did not send %q", clock.Now(), flush.want)
+		}
+	}
+	if queue.Len() != 0 {
+		t.Errorf("Len() after flushes = %d", queue.Len())
+	}
+}
+
+func TestDurableQueueDeliveryWindowUnknownTimeZone(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	queue, _ := newWindowQueue(t, s)
+
+	msgs := testMessages(2)
+	msgs[0].Meta = map[string]string{expo.MetaTimeZone: "Asia/Tokyo"}
+	msgs[1].Meta = map[string]string{expo.MetaTimeZone: "Nowhere/Special"}
+	if _, err := queue.Publish(context.Background(), msgs); err == nil {
+		t.Fatal("Publish() with an unknown time zone succeeded")
+	}
+	if len(s.Requests()) != 0 || queue.Len() != 0 {
+		t.Errorf("Publish() sent %d requests and queued %d messages", len(s.Requests()), queue.Len())
+	}
+}
+
+// locationResolver resolves each user to a token and time zone
+type locationResolver map[string]string
+
+func (r locationResolver) Resolve(ctx context.Context, userIDs []string) (map[string][]*expo.Token, error) {
+	tokens := make(map[string][]*expo.Token)
+	for i, userID := range userIDs {
+		tokens[userID] = []*expo.Token{testToken(i)}
+	}
+	return tokens, nil
+}
+
+func (r locationResolver) ResolveLocations(ctx context.Context, userIDs []string) (map[string]*time.Location, error) {
+	locations := make(map[string]*time.Location)
+	for _, userID := range userIDs {
+		loc, err := time.LoadLocation(r[userID])
+		if err != nil {
+			return nil, err
+		}
+		locations[userID] = loc
+	}
+	return locations, nil
+}
+
+func TestDurableQueuePublishToUsers(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	resolver := locationResolver{"tokyo": "Asia/Tokyo", "paris": "Europe/Paris"}
+	queue, clock := newWindowQueue(t, s, expo.WithTokenResolver(resolver))
+	ctx := context.Background()
+
+	responses, err := queue.PublishToUsers(ctx, []string{"tokyo", "paris"}, &expo.Message{Body: "hello"})
+	if !errors.Is(err, expo.ErrDeferred) {
+		t.Fatalf("PublishToUsers() = %v, want %v", err, expo.ErrDeferred)
+	}
+	if len(responses) != 1 || queue.Len() != 1 {
+		t.Fatalf("PublishToUsers() = %d responses with %d messages deferred", len(responses), queue.Len())
+	}
+	if to := sentMessages(t, s.Requests()[0].Body)[0]["to"].([]any); to[0] != string(*testToken(0)) {
+		t.Errorf("sent to %v, want the token of tokyo", to)
+	}
+
+	// 09:00 in Paris is 08:00 UTC
+	clock.Advance(5 * time.Hour)
+	if err := queue.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	if len(s.Requests()) != 2 || queue.Len() != 0 {
+		t.Errorf("Flush() left %d messages after %d requests", queue.Len(), len(s.Requests()))
+	}
+}
+
+func TestDurableQueueFlushChunksByRecipients(t *testing.T) {
+	var outage atomic.Bool
+	outage.Store(true)
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		if outage.Load() {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{})
+			return
+		}
+		okTickets(t, w, req)
+	})
+	queue, _ := newTestQueue(t, s, nil, expo.WithRetryConfig(&expo.RetryConfig{MaxRetries: 0}))
+	ctx := context.Background()
+
+	for i := range 3 {
+		msg := &expo.Message{To: testTokens(60), Body: fmt.Sprintf("message %d", i)}
+		if _, err := queue.PublishSingle(ctx, msg); !errors.Is(err, expo.ErrQueued) {
+			t.Fatalf("PublishSingle() = %v, want %v", err, expo.ErrQueued)
+		}
+	}
+	if _, err := queue.PublishSingle(ctx, &expo.Message{To: testTokens(101)}); err == nil || errors.Is(err, expo.ErrQueued) {
+		t.Fatalf("PublishSingle() of a message to 101 recipients = %v", err)
+	}
+
+	outage.Store(false)
+	before := len(s.Requests())
+	if err := queue.Flush(ctx); err != nil {
+		t.Fatalf("Flush() = %v", err)
+	}
+	requests := s.Requests()[before:]
+	if len(requests) != 3 {
+		t.Errorf("Flush() sent %d requests, want 3", len(requests))
+	}
+	for _, req := range requests {
+		if n := recipientCount(t, req.Body); n > 100 {
+			t.Errorf("Flush() sent a request to %d recipients", n)
+		}
+	}
+	if queue.Len() != 0 {
+		t.Errorf("Len() after Flush() = %d", queue.Len())
+	}
+}
+
+func TestNewDurableQueueKeepsConfig(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	cnf := &expo.DurableQueueConfig{Path: filepath.Join(t.TempDir(), "queue")}
+	queue, err := expo.NewDurableQueue(expo.NewClient(expo.WithHost(s.URL)), cnf)
+	if err != nil {
+		t.Fatalf("NewDurableQueue() = %v", err)
+	}
+	if cnf.MaxMessages != 0 || cnf.TTL != 0 || cnf.FlushInterval != 0 {
+		t.Errorf("config changed to %+v", *cnf)
+	}
+	queue.Close()
+	queue.Close()
+}
@@ -0,0 +1,110 @@
+package expo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize keeps unusually large buffers from being pinned by the pool
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// encodeJSON encodes v into a pooled buffer. The caller must release it with putBuffer.
+func encodeJSON(v any) (*bytes.Buffer, error) {
+	buf := getBuffer()
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		putBuffer(buf)
+		return nil, err
+	}
+	return buf, nil
+}
+
+// encodeBody encodes v and gzips it when compression applies, reporting whether
+// it did. The caller must release the returned buffer with putBuffer.
+func (c *Client) encodeBody(v any) (*bytes.Buffer, bool, error) {
+	buf, err := encodeJSON(v)
+	if err != nil {
+		return nil, false, err
+	}
+	if !c.shouldCompress(buf.Len()) {
+		return buf, false, nil
+	}
+
+	compressed := getBuffer()
+	err = gzipInto(compressed, buf.Bytes())
+	putBuffer(buf)
+	if err != nil {
+		putBuffer(compressed)
+		return nil, false, err
+	}
+	return compressed, true, nil
+}
+
+// streamBody encodes v straight into the returned reader as it is consumed, so
+// the marshaled body is never held in memory as a whole.
+func streamBody(v any, compress bool) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var w io.Writer = pw
+		var gzWriter *gzip.Writer
+		if compress {
+			gzWriter = gzipWriterPool.Get().(*gzip.Writer)
+			gzWriter.Reset(pw)
+			w = gzWriter
+		}
+
+		err := json.NewEncoder(w).Encode(v)
+		if gzWriter != nil {
+			if closeErr := gzWriter.Close(); err == nil {
+				err = closeErr
+			}
+			gzipWriterPool.Put(gzWriter)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// prepareBody returns a function producing a fresh request body for each
+// attempt, whether that body is gzipped, and a func releasing its resources.
+// Streamed bodies are re-encoded on every attempt; since their size is not
+// known up front they are compressed whenever gzip is enabled.
+func (c *Client) prepareBody(v any) (func() io.Reader, bool, func(), error) {
+	if c.cnf.StreamBody {
+		compressed := c.cnf.EnableGzip
+		newBody := func() io.Reader {
+			return streamBody(v, compressed)
+		}
+		return newBody, compressed, func() {}, nil
+	}
+
+	body, compressed, err := c.encodeBody(v)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	newBody := func() io.Reader {
+		return bytes.NewReader(body.Bytes())
+	}
+	return newBody, compressed, func() { putBuffer(body) }, nil
+}
@@ -0,0 +1,50 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+// benchmarkMessages returns n messages like a typical publish request
+func benchmarkMessages(n int) []*Message {
+	msgs := make([]*Message, n)
+	for i := range msgs {
+		msgs[i] = &Message{
+			To:    []*Token{MustParseToken(fmt.Sprintf("ExponentPushToken[token-%d]", i))},
+			Title: "New message",
+			Body:  fmt.Sprintf("You have %d unread messages", i),
+			Data:  Data{"conversationId": fmt.Sprintf("conversation-%d", i)},
+			Sound: "default",
+		}
+	}
+	return msgs
+}
+
+// BenchmarkEncodeBody compares the pooled encoding of publish bodies with
+// plain json.Marshal, which allocates a new buffer for every request
+func BenchmarkEncodeBody(b *testing.B) {
+	msgs := benchmarkMessages(100)
+
+	b.Run("json.Marshal", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := json.Marshal(msgs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	for _, gzip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("gzip=%t", gzip), func(b *testing.B) {
+			c := NewClient(WithGzipEnabled(gzip))
+			b.ReportAllocs()
+			for range b.N {
+				body, _, err := c.encodeBody(msgs)
+				if err != nil {
+					b.Fatal(err)
+				}
+				putBuffer(body)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package expo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+)
+
+// ErrorClass describes the kind of failure behind an error
+type ErrorClass int
+
+const (
+	// ErrorClassUnknown is an error the client does not recognize; it is not retried
+	ErrorClassUnknown ErrorClass = iota
+	// ErrorClassTemporary is a network failure, such as a timeout or reset
+	// connection, that may succeed when retried
+	ErrorClassTemporary
+	// ErrorClassPermanent is a failure that will repeat, such as an unknown
+	// host, an invalid certificate or a request that cannot be built
+	ErrorClassPermanent
+	// ErrorClassHTTP is a response with an error status from the API
+	ErrorClassHTTP
+	// ErrorClassDecode is a response body that could not be decoded
+	ErrorClassDecode
+	// ErrorClassCanceled is a canceled call or an expired deadline
+	ErrorClassCanceled
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorClassTemporary:
+		return "temporary"
+	case ErrorClassPermanent:
+		return "permanent"
+	case ErrorClassHTTP:
+		return "http"
+	case ErrorClassDecode:
+		return "decode"
+	case ErrorClassCanceled:
+		return "canceled"
+	default:
+		return "unknown"
+	}
+}
+
+// ClassifyError returns the kind of failure behind err
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassUnknown
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassCanceled
+	}
+
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) && serverErr.Response != nil {
+		return ErrorClassHTTP
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return ErrorClassPermanent
+		}
+		return ErrorClassTemporary
+	}
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return ErrorClassPermanent
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return ErrorClassDecode
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTemporary
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return ErrorClassTemporary
+	}
+
+	// Other socket failures are treated as transient, while a round trip that
+	// failed before reaching the network, e.g. for an unsupported scheme, is not
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return ErrorClassTemporary
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return ErrorClassPermanent
+	}
+	return ErrorClassUnknown
+}
+
+// NonRetryableError is returned by WithRetry when an attempt fails with an
+// error that retrying cannot fix
+type NonRetryableError struct {
+	Class ErrorClass
+	Err   error
+}
+
+func (e *NonRetryableError) Error() string {
+	return fmt.Sprintf("%s error: %v", e.Class, e.Err)
+}
+
+func (e *NonRetryableError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,49 @@
+package expotest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// TestDedupeStore checks that a DedupeStore follows the contract of
+// expo.DedupeStore. newStore must return an empty store for each call.
+func TestDedupeStore(t *testing.T, newStore func(t *testing.T) expo.DedupeStore) {
+	ctx := context.Background()
+	reserve := func(t *testing.T, store expo.DedupeStore, keys []string, want ...string) {
+		t.Helper()
+		reserved, err := store.Reserve(ctx, keys, time.Hour)
+		if err != nil {
+			t.Fatalf("Reserve(%q) = %v", keys, err)
+		}
+		if !slices.Equal(reserved, want) {
+			t.Errorf("Reserve(%q) = %q, want %q", keys, reserved, want)
+		}
+	}
+
+	t.Run("new keys in order", func(t *testing.T) {
+		reserve(t, newStore(t), []string{"b", "a", "c"}, "b", "a", "c")
+	})
+	t.Run("no keys", func(t *testing.T) {
+		reserve(t, newStore(t), nil)
+	})
+	t.Run("repeated key", func(t *testing.T) {
+		reserve(t, newStore(t), []string{"a", "b", "a", "a"}, "a", "b")
+	})
+	t.Run("reserved keys", func(t *testing.T) {
+		store := newStore(t)
+		reserve(t, store, []string{"a", "b"}, "a", "b")
+		reserve(t, store, []string{"b", "c", "a"}, "c")
+	})
+	t.Run("released keys", func(t *testing.T) {
+		store := newStore(t)
+		reserve(t, store, []string{"a", "b"}, "a", "b")
+		if err := store.Release(ctx, []string{"a", "x"}); err != nil {
+			t.Fatalf("Release() = %v", err)
+		}
+		reserve(t, store, []string{"a", "b"}, "a")
+	})
+}
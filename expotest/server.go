@@ -0,0 +1,183 @@
+// Package expotest provides a fake Expo push service for tests and load testing
+package expotest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// Server is an in-process fake of the Expo push API. It accepts publish and
+// receipt requests on any API prefix, issues one ticket per recipient and
+// reports an "ok" receipt for it unless told otherwise. Every response carries
+// an X-Request-Id header numbering the requests.
+type Server struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	latency      time.Duration
+	failures     []int
+	tokenErrors  map[expo.Token]expo.ErrorMsg
+	receipts     map[string]*expo.PushReceipt
+	nextTicketID int
+	requests     int
+	messages     int
+}
+
+// NewServer starts a fake Expo push server. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		tokenErrors: make(map[expo.Token]expo.ErrorMsg),
+		receipts:    make(map[string]*expo.PushReceipt),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// NewClient returns a client pointed at the fake server
+func (s *Server) NewClient(opts ...expo.Option) *expo.Client {
+	return expo.NewClient(append([]expo.Option{expo.WithHost(s.URL)}, opts...)...)
+}
+
+// SetLatency delays every response by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailRequests makes the next count requests fail with the given HTTP status
+func (s *Server) FailRequests(status int, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < count; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// FailToken makes receipts for the given token report the error code
+func (s *Server) FailToken(token expo.Token, code expo.ErrorMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenErrors[token] = code
+}
+
+// Requests returns the number of requests handled so far
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Messages returns the number of messages accepted so far
+func (s *Server) Messages() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.messages
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	w.Header().Set("X-Request-Id", fmt.Sprintf("request-%d", s.requests))
+	latency := s.latency
+	status := 0
+	if len(s.failures) > 0 {
+		status, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	body := io.Reader(r.Body)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gzReader.Close()
+		body = gzReader
+	}
+
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/push/send"):
+		s.handleSend(w, body)
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/push/getReceipts"):
+		s.handleReceipts(w, body)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleSend(w http.ResponseWriter, body io.Reader) {
+	var msgs []*expo.Message
+	if err := json.NewDecoder(body).Decode(&msgs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	var tickets []*expo.MessageResponse
+	for _, msg := range msgs {
+		s.messages++
+		for _, token := range msg.To {
+			s.nextTicketID++
+			id := fmt.Sprintf("ticket-%d", s.nextTicketID)
+			tickets = append(tickets, &expo.MessageResponse{ID: id, Status: "ok"})
+
+			receipt := &expo.PushReceipt{Status: "ok"}
+			if token != nil {
+				if code, ok := s.tokenErrors[*token]; ok {
+					receipt = &expo.PushReceipt{
+						Status:  "error",
+						Message: string(code),
+						Details: expo.Data{"error": string(code)},
+					}
+				}
+			}
+			s.receipts[id] = receipt
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, &expo.Response{Data: tickets})
+}
+
+func (s *Server) handleReceipts(w http.ResponseWriter, body io.Reader) {
+	var req expo.PushReceiptRequest
+	if err := json.NewDecoder(body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	receipts := make(map[string]*expo.PushReceipt)
+	for _, id := range req.IDs {
+		if receipt, ok := s.receipts[id]; ok {
+			receipts[id] = receipt
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, &expo.PushReceiptResponse{Data: receipts})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
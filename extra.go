@@ -0,0 +1,66 @@
+package expo
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+
+	"dezeto/expo-push-notification/internal/payload"
+)
+
+// messageFields returns the JSON names of the fields of Message
+var messageFields = sync.OnceValue(func() map[string]bool {
+	return payload.FieldNames(reflect.TypeOf(Message{}))
+})
+
+// MarshalJSON encodes the message, adding a zero badge if ClearBadge is set and
+// the fields of Extra to the top-level object
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	data, err := json.Marshal(message(m))
+	if err != nil {
+		return nil, err
+	}
+
+	var members []payload.Member
+	if m.ClearBadge && m.Badge == 0 {
+		members = append(members, payload.Member{Name: "badge", Value: 0})
+	}
+	for _, name := range slices.Sorted(maps.Keys(m.Extra)) {
+		if messageFields()[name] {
+			return nil, fmt.Errorf("extra field %q collides with a message field", name)
+		}
+		members = append(members, payload.Member{Name: name, Value: m.Extra[name]})
+	}
+	data, err = payload.Extend(data, members...)
+	if err != nil {
+		return nil, fmt.Errorf("extra %w", err)
+	}
+	return data, nil
+}
+
+// extraFields returns the members of the JSON object data that are not Message fields
+func extraFields(data []byte) (map[string]any, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	var extra map[string]any
+	for name, raw := range all {
+		if messageFields()[name] {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		if extra == nil {
+			extra = make(map[string]any)
+		}
+		extra[name] = value
+	}
+	return extra, nil
+}
@@ -0,0 +1,41 @@
+package expo_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func BenchmarkMessageMarshalJSON(b *testing.B) {
+	base := expo.Message{
+		To:    []*expo.Token{testToken(0)},
+		Title: "New message",
+		Body:  "You have 3 unread messages",
+		Data:  expo.Data{"conversationId": "conversation-1"},
+		Sound: "default",
+	}
+	withExtra := base
+	withExtra.Extra = map[string]any{"futureField": "value"}
+	clearBadge := base
+	clearBadge.ClearBadge = true
+
+	cases := []struct {
+		name string
+		msg  *expo.Message
+	}{
+		{"plain", &base},
+		{"extra", &withExtra},
+		{"clear badge", &clearBadge},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if _, err := json.Marshal(tc.msg); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
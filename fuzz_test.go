@@ -0,0 +1,157 @@
+package expo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func FuzzParseToken(f *testing.F) {
+	for _, seed := range []string{
+		"ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]",
+		"ExponentPushToken[]",
+		"ExponentPushToken",
+		"ExpoPushToken[xxxxxxxxxxxxxxxxxxxxxx]",
+		"exponentpushtoken[abc]",
+		"",
+		" ExponentPushToken[abc]",
+		"ExponentPushToken[abc]\x00",
+		strings.Repeat("ab", 32),
+		"dGVzdA:" + strings.Repeat("x", 140),
+		"ExponentPushToken[" + strings.Repeat("é", 1000) + "]",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		token, err := ParseToken(input)
+		if err != nil {
+			if token != nil {
+				t.Fatalf("ParseToken(%q) returned a token with error %v", input, err)
+			}
+			if IsPushTokenValid(input) || ClassifyToken(input) == TokenKindExpo {
+				t.Fatalf("invalid token %q reported valid", input)
+			}
+			return
+		}
+		if string(*token) != input {
+			t.Fatalf("ParseToken(%q) = %q", input, *token)
+		}
+		if !IsPushTokenValid(input) || ClassifyToken(input) != TokenKindExpo {
+			t.Fatalf("parsed token %q not reported valid", input)
+		}
+		if redacted := token.Redacted(); len(input) > 12 && strings.Contains(redacted, input) {
+			t.Fatalf("Redacted() = %q leaks the token", redacted)
+		}
+	})
+}
+
+// fuzzDecode decodes data as the client does in both decode modes and checks
+// that a response strict mode accepts is also accepted in tolerant mode
+func fuzzDecode[T any](t *testing.T, data []byte, check func(*Client, T) error, inspect func(T)) {
+	var strictErr error
+	for _, mode := range []DecodeMode{DecodeStrict, DecodeTolerant} {
+		c := NewClient(WithDecodeMode(mode))
+		var v T
+		err := c.decodeResponse(bytes.NewReader(data), &v)
+		if err == nil {
+			err = check(c, v)
+		}
+		if err == nil {
+			inspect(v)
+		}
+		if mode == DecodeStrict {
+			strictErr = err
+		} else if strictErr == nil && err != nil {
+			t.Fatalf("tolerant decoding failed where strict decoding succeeded: %v", err)
+		}
+	}
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"data":[{"status":"ok","id":"XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX"}]}`,
+		`{"data":[{"status":"error","message":"\"ExponentPushToken[xxx]\" is not a registered push notification recipient","details":{"error":"DeviceNotRegistered"}}]}`,
+		`{"data":[{"status":"ok","id":"a","newField":{"nested":[1,2,3]}}]}`,
+		`{"errors":[{"code":"PUSH_TOO_MANY_EXPERIENCE_IDS","message":"too many"}]}`,
+		`{"data":[null]}`,
+		`{"data":{"status":"ok"}}`,
+		`{"data":[{"status":1,"id":[]}]}`,
+		`{"data":[{"details":{"error":42,"retryAfter":{"seconds":5}}}]}`,
+		`{"data":[{"status":"ok"}],"extra":true}`,
+		`{"data":[`,
+		`[]`,
+		`null`,
+		``,
+		`{"data":[` + strings.Repeat(`{"status":"ok","id":"x"},`, 1000) + `{"status":"ok"}]}`,
+		`{"data":"` + strings.Repeat("A", 1<<16) + `"}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDecode(t, data, func(c *Client, r *Response) error {
+			if r == nil {
+				return nil
+			}
+			return c.checkTickets(r.Data)
+		}, func(r *Response) {
+			if r == nil {
+				return
+			}
+			for _, ticket := range r.Data {
+				if ticket == nil {
+					continue
+				}
+				ticket.IsOk()
+				ticket.ErrorCode()
+				if !json.Valid(ticket.Raw) {
+					t.Fatalf("ticket Raw is not valid JSON: %q", ticket.Raw)
+				}
+			}
+		})
+	})
+}
+
+func FuzzDecodePushReceiptResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"data":{"XXXXXXXX-XXXX-XXXX-XXXX-XXXXXXXXXXXX":{"status":"ok"}}}`,
+		`{"data":{"a":{"status":"error","message":"The Apple Push Notification service failed to send the notification","details":{"error":"DeviceNotRegistered"}}}}`,
+		`{"data":{"a":{"status":"ok","__debug":{"x":1}}}}`,
+		`{"data":{"a":null}}`,
+		`{"data":{}}`,
+		`{"data":[]}`,
+		`{"errors":[{"code":"INTERNAL_SERVER_ERROR","message":"An unknown error occurred."}]}`,
+		`{"data":{"a":{"details":{"error":["DeviceNotRegistered"]}}}}`,
+		`{"data":{"a":{"status":"ok"}},"data":{"b":{"status":"ok"}}}`,
+		`{"data":{"a":`,
+		`"data"`,
+		``,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzDecode(t, data, func(c *Client, r *PushReceiptResponse) error {
+			if r == nil {
+				return nil
+			}
+			return c.checkReceipts(r.Data)
+		}, func(r *PushReceiptResponse) {
+			if r == nil {
+				return
+			}
+			for id, receipt := range r.Data {
+				if receipt == nil {
+					continue
+				}
+				receipt.IsOk()
+				receipt.ErrorCode()
+				if !json.Valid(receipt.Raw) {
+					t.Fatalf("receipt %q Raw is not valid JSON: %q", id, receipt.Raw)
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package expo
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// cancelOnClose cancels the winning attempt's context once its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isHedgeWinner reports whether an attempt produced a definitive answer
+func isHedgeWinner(r hedgeResult) bool {
+	return r.err == nil && r.resp != nil && !IsRetryableError(r.resp.StatusCode)
+}
+
+// hedged runs send and, if hedging is configured, fires up to MaxHedges
+// identical attempts spaced HedgeDelay apart while none has answered. The first
+// definitive response wins and the remaining attempts are cancelled.
+func (c *Client) hedged(ctx context.Context, send func(ctx context.Context) (*http.Response, error)) (*http.Response, error) {
+	if c.cnf.MaxHedges <= 0 {
+		return send(ctx)
+	}
+
+	results := make(chan hedgeResult, c.cnf.MaxHedges+1)
+	var cancels []context.CancelFunc
+	launch := func() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := send(attemptCtx)
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+	}
+
+	launch()
+	inflight := 1
+	hedgeTimer := c.cnf.Clock.After(c.cnf.HedgeDelay)
+
+	for {
+		select {
+		case <-hedgeTimer:
+			if len(cancels) <= c.cnf.MaxHedges {
+				launch()
+				inflight++
+				hedgeTimer = c.cnf.Clock.After(c.cnf.HedgeDelay)
+			}
+		case r := <-results:
+			inflight--
+			if isHedgeWinner(r) || inflight == 0 {
+				c.abandonHedges(results, inflight, cancels, r.index)
+				if r.resp == nil {
+					cancels[r.index]()
+					return nil, r.err
+				}
+				r.resp.Body = &cancelOnClose{ReadCloser: r.resp.Body, cancel: cancels[r.index]}
+				return r.resp, r.err
+			}
+			// A failed attempt loses to whichever attempt is still in flight
+			if r.resp != nil {
+				r.resp.Body.Close()
+			}
+			cancels[r.index]()
+		}
+	}
+}
+
+// abandonHedges cancels every attempt except the winner and discards their responses
+func (c *Client) abandonHedges(results <-chan hedgeResult, inflight int, cancels []context.CancelFunc, winner int) {
+	for i, cancel := range cancels {
+		if i != winner {
+			cancel()
+		}
+	}
+	go func() {
+		for ; inflight > 0; inflight-- {
+			if r := <-results; r.resp != nil {
+				r.resp.Body.Close()
+			}
+		}
+	}()
+}
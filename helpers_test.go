@@ -0,0 +1,211 @@
+package expo_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// recordedRequest is a request received by an apiServer, with its body decompressed
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// apiServer is an httptest server answering every request with respond
+type apiServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+// newAPIServer starts an apiServer; it is closed when the test ends
+func newAPIServer(t testing.TB, respond func(w http.ResponseWriter, req recordedRequest)) *apiServer {
+	t.Helper()
+	s := &apiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gzReader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = gzReader
+		}
+		data, err := io.ReadAll(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req := recordedRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: data}
+		s.mu.Lock()
+		s.requests = append(s.requests, req)
+		s.mu.Unlock()
+		respond(w, req)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Requests returns the requests received so far
+func (s *apiServer) Requests() []recordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest(nil), s.requests...)
+}
+
+// newTestClient returns a client of s that never waits for backoff
+func newTestClient(s *apiServer, opts ...expo.Option) (*expo.Client, *instantClock) {
+	clock := newInstantClock()
+	opts = append([]expo.Option{expo.WithHost(s.URL), expo.WithClock(clock)}, opts...)
+	return expo.NewClient(opts...), clock
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// sentMessages decodes the messages of a publish request body
+func sentMessages(t testing.TB, body []byte) []map[string]any {
+	t.Helper()
+	var msgs []map[string]any
+	if err := json.Unmarshal(body, &msgs); err != nil {
+		t.Fatalf("decode publish request: %v", err)
+	}
+	return msgs
+}
+
+// recipientCount returns the number of recipients of a publish request body
+func recipientCount(t testing.TB, body []byte) int {
+	t.Helper()
+	n := 0
+	for _, msg := range sentMessages(t, body) {
+		n += len(msg["to"].([]any))
+	}
+	return n
+}
+
+// okTickets answers a publish request with an "ok" ticket per recipient,
+// numbered from the ID of the first
+func okTickets(t testing.TB, w http.ResponseWriter, req recordedRequest) {
+	t.Helper()
+	n := recipientCount(t, req.Body)
+	tickets := make([]map[string]any, n)
+	for i := range tickets {
+		tickets[i] = map[string]any{"status": "ok", "id": fmt.Sprintf("ticket-%d", i)}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"data": tickets})
+}
+
+// okTicketsBody returns a publish response body with n "ok" tickets
+func okTicketsBody(n int) []byte {
+	tickets := make([]map[string]any, n)
+	for i := range tickets {
+		tickets[i] = map[string]any{"status": "ok", "id": fmt.Sprintf("ticket-%d", i)}
+	}
+	body, _ := json.Marshal(map[string]any{"data": tickets})
+	return body
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// cannedClient returns a client whose requests are answered with status and
+// body without a server, so benchmarks measure the client rather than the network
+func cannedClient(status int, body []byte, opts ...expo.Option) *expo.Client {
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		io.Copy(io.Discard, req.Body)
+		req.Body.Close()
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+			Request:    req,
+		}, nil
+	})
+	opts = append([]expo.Option{expo.WithHttpClient(&http.Client{Transport: transport}), expo.WithClock(newInstantClock())}, opts...)
+	return expo.NewClient(opts...)
+}
+
+// testToken returns a valid Expo push token numbered n
+func testToken(n int) *expo.Token {
+	return expo.MustParseToken(fmt.Sprintf("ExponentPushToken[token-%d]", n))
+}
+
+// testTokens returns n distinct tokens
+func testTokens(n int) []*expo.Token {
+	tokens := make([]*expo.Token, n)
+	for i := range tokens {
+		tokens[i] = testToken(i)
+	}
+	return tokens
+}
+
+// testMessages returns n messages, each to its own token
+func testMessages(n int) []*expo.Message {
+	msgs := make([]*expo.Message, n)
+	for i := range msgs {
+		msgs[i] = &expo.Message{To: []*expo.Token{testToken(i)}, Body: fmt.Sprintf("message %d", i)}
+	}
+	return msgs
+}
+
+// instantClock is an expo.Clock whose timers fire at once. It advances by
+// every duration waited for and records them, so tests can check backoff.
+type instantClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+func newInstantClock() *instantClock {
+	return &instantClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func (c *instantClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *instantClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+		c.waits = append(c.waits, d)
+	}
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func (c *instantClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Waits returns the durations waited for so far
+func (c *instantClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,200 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResultSink receives the results produced by the push workflow. Workflows run
+// concurrently share the sink, so it must be safe for concurrent use.
+type ResultSink interface {
+	Put(ctx context.Context, results []*PushResult) error
+}
+
+// ResultSinkFunc adapts an ordinary function to the ResultSink interface
+type ResultSinkFunc func(ctx context.Context, results []*PushResult) error
+
+// Put calls f(ctx, results)
+func (f ResultSinkFunc) Put(ctx context.Context, results []*PushResult) error {
+	return f(ctx, results)
+}
+
+// emitResults hands the results to the configured sink, if any
+func (c *Client) emitResults(ctx context.Context, results []*PushResult) error {
+	if c.cnf.ResultSink == nil || len(results) == 0 {
+		return nil
+	}
+	if err := c.cnf.ResultSink.Put(ctx, results); err != nil {
+		return fmt.Errorf("failed to store push results: %w", err)
+	}
+	return nil
+}
+
+// unknownErrorCode groups failed results that carry no Expo error code
+const unknownErrorCode = "Unknown"
+
+// ErrorEvent is an aggregated report of failed results sharing the same error code
+type ErrorEvent struct {
+	Code         string
+	Count        int
+	SampleTokens []*Token
+	WindowStart  time.Time
+	WindowEnd    time.Time
+}
+
+// SuppressionConfig holds configuration for error suppression
+type SuppressionConfig struct {
+	// Window is the period over which identical errors are aggregated
+	Window time.Duration
+	// Threshold is the number of results per error code forwarded individually in each window
+	Threshold int
+	// MaxSamples is the maximum number of tokens recorded in each ErrorEvent
+	MaxSamples int
+	// Clock defaults to SystemClock
+	Clock Clock
+}
+
+// DefaultSuppressionConfig provides sensible defaults for error suppression
+func DefaultSuppressionConfig() *SuppressionConfig {
+	return &SuppressionConfig{
+		Window:     time.Minute,
+		Threshold:  10,
+		MaxSamples: 5,
+	}
+}
+
+type errorBucket struct {
+	forwarded  int
+	suppressed int
+	samples    []*Token
+}
+
+// ErrorSuppressor is a ResultSink that forwards results to another sink, but
+// once an error code exceeds the threshold within a window the remaining
+// failures are coalesced into a single ErrorEvent emitted when the window ends.
+type ErrorSuppressor struct {
+	next    ResultSink
+	onEvent func(ErrorEvent)
+	cnf     *SuppressionConfig
+
+	mu          sync.Mutex
+	windowStart time.Time
+	buckets     map[string]*errorBucket
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// NewErrorSuppressor creates an ErrorSuppressor in front of next, which may be nil.
+// Close must be called to stop the background flush and emit pending events.
+func NewErrorSuppressor(next ResultSink, onEvent func(ErrorEvent), cnf *SuppressionConfig) *ErrorSuppressor {
+	if cnf == nil {
+		cnf = DefaultSuppressionConfig()
+	}
+	// Defaults are applied to a copy, leaving the caller's config as it is
+	c := *cnf
+	if c.Clock == nil {
+		c.Clock = SystemClock
+	}
+	s := &ErrorSuppressor{
+		next:        next,
+		onEvent:     onEvent,
+		cnf:         &c,
+		windowStart: c.Clock.Now(),
+		buckets:     make(map[string]*errorBucket),
+		done:        make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.loop()
+	return s
+}
+
+// Put forwards results to the next sink, holding back failures over the threshold
+func (s *ErrorSuppressor) Put(ctx context.Context, results []*PushResult) error {
+	forward := make([]*PushResult, 0, len(results))
+
+	s.mu.Lock()
+	for _, result := range results {
+		if result.IsSuccessful() || (result.Error == nil && result.ErrorCode() == "") {
+			forward = append(forward, result)
+			continue
+		}
+
+		code := result.ErrorCode()
+		if code == "" {
+			code = unknownErrorCode
+		}
+		bucket, ok := s.buckets[code]
+		if !ok {
+			bucket = &errorBucket{}
+			s.buckets[code] = bucket
+		}
+		if bucket.forwarded < s.cnf.Threshold {
+			bucket.forwarded++
+			forward = append(forward, result)
+			continue
+		}
+		bucket.suppressed++
+		if result.Token != nil && len(bucket.samples) < s.cnf.MaxSamples {
+			bucket.samples = append(bucket.samples, result.Token)
+		}
+	}
+	s.mu.Unlock()
+
+	if s.next == nil || len(forward) == 0 {
+		return nil
+	}
+	return s.next.Put(ctx, forward)
+}
+
+// Flush emits events for the current window and starts a new one
+func (s *ErrorSuppressor) Flush() {
+	s.mu.Lock()
+	start, end := s.windowStart, s.cnf.Clock.Now()
+	buckets := s.buckets
+	s.windowStart = end
+	s.buckets = make(map[string]*errorBucket)
+	s.mu.Unlock()
+
+	if s.onEvent == nil {
+		return
+	}
+	for code, bucket := range buckets {
+		if bucket.suppressed == 0 {
+			continue
+		}
+		s.onEvent(ErrorEvent{
+			Code:         code,
+			Count:        bucket.suppressed,
+			SampleTokens: bucket.samples,
+			WindowStart:  start,
+			WindowEnd:    end,
+		})
+	}
+}
+
+// Close stops the background flush and emits any pending events. Calls
+// after the first do nothing.
+func (s *ErrorSuppressor) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		s.Flush()
+	})
+}
+
+func (s *ErrorSuppressor) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.cnf.Clock.After(s.cnf.Window):
+			s.Flush()
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package expo_test
+
+import (
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestErrorSuppressorCloseTwice(t *testing.T) {
+	cnf := expo.DefaultSuppressionConfig()
+	suppressor := expo.NewErrorSuppressor(nil, nil, cnf)
+	if cnf.Clock != nil {
+		t.Errorf("config clock set to %v", cnf.Clock)
+	}
+	suppressor.Close()
+	suppressor.Close()
+}
@@ -5,17 +5,26 @@ import (
 	"time"
 )
 
-// PushClient defines the interface for sending push notifications
-type PushClient interface {
+// Publisher sends push notifications
+type Publisher interface {
 	// PublishSingle sends a single push notification
 	PublishSingle(ctx context.Context, msg *Message) ([]*MessageResponse, error)
-	
+
 	// Publish sends multiple push notifications at once
 	Publish(ctx context.Context, msgs []*Message) ([]*MessageResponse, error)
-	
+}
+
+// ReceiptFetcher fetches the receipts of sent push notifications
+type ReceiptFetcher interface {
 	// GetPushReceipts fetches push receipts for the given ticket IDs
 	GetPushReceipts(ctx context.Context, ticketIDs []string) (map[string]*PushReceipt, error)
-	
+}
+
+// PushClient defines the interface for sending push notifications
+type PushClient interface {
+	Publisher
+	ReceiptFetcher
+
 	// SendPushNotificationsWithReceipts sends push notifications and waits for receipts
 	SendPushNotificationsWithReceipts(ctx context.Context, messages []*Message, receiptDelay time.Duration) ([]*PushResult, error)
 }
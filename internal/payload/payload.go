@@ -0,0 +1,62 @@
+// Package payload builds the JSON objects sent to Expo. Message declares the
+// fields Expo documents, and this package adds the members its struct tags
+// cannot express, such as a zero badge or fields not known to the client yet.
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Member is a member to add to a JSON object
+type Member struct {
+	Name  string
+	Value any
+}
+
+// Extend returns the JSON object obj with members appended in order. obj is
+// returned as is when there are no members to add.
+func Extend(obj []byte, members ...Member) ([]byte, error) {
+	obj = bytes.TrimSpace(obj)
+	if len(obj) < 2 || obj[0] != '{' || obj[len(obj)-1] != '}' {
+		return nil, fmt.Errorf("payload is not a JSON object: %.20q", obj)
+	}
+	if len(members) == 0 {
+		return obj, nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(obj[:len(obj)-1])
+	empty := len(bytes.TrimSpace(obj[1:len(obj)-1])) == 0
+	for _, member := range members {
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", member.Name, err)
+		}
+		if !empty {
+			buf.WriteByte(',')
+		}
+		empty = false
+		key, _ := json.Marshal(member.Name)
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// FieldNames returns the JSON names of the tagged fields of the struct type t
+func FieldNames(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
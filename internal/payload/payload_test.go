@@ -0,0 +1,131 @@
+package payload_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/internal/payload"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+type goldenMessage struct {
+	name string
+	msg  expo.Message
+}
+
+// goldenMessages covers every field of Message, grouped by the platforms it
+// applies to. Each is addressed to one token, so that its golden file holds
+// only the fields under test.
+var goldenMessages = []goldenMessage{
+	{"minimal", expo.Message{}},
+	{"common_title_body", expo.Message{Title: "Title", Body: "Body"}},
+	{"common_data", expo.Message{Data: expo.Data{"id": "42", "kind": "chat"}}},
+	{"common_sound", expo.Message{Sound: "default"}},
+	{"common_ttl", expo.Message{TTL: 3600}},
+	{"common_expiration", expo.Message{Expiration: 1767225600}},
+	{"common_priority", expo.Message{Priority: expo.HighPriority}},
+	{"common_category", expo.Message{CategoryID: "reply"}},
+	{"common_rich_content", expo.Message{RichContent: map[string]string{"image": "https://example.com/image.png"}}},
+	{"common_collapse_id", expo.Message{CollapseID: "score-update"}},
+	{"common_thread_id", expo.Message{ThreadID: "conversation-42"}},
+	{"common_extra", expo.Message{Extra: map[string]any{"zeta": true, "alpha": 1}}},
+	{"common_meta_not_sent", expo.Message{Body: "Body", Meta: map[string]string{"notificationId": "n-1"}}},
+	{"ios_badge", expo.Message{Badge: 3}},
+	{"ios_clear_badge", expo.Message{ClearBadge: true}},
+	{"ios_subtitle", expo.Message{Subtitle: "Subtitle"}},
+	{"ios_interruption_level", expo.Message{InterruptionLevel: expo.InterruptionLevelTimeSensitive}},
+	{"ios_mutable_content", expo.Message{MutableContent: true}},
+	{"ios_content_available", expo.Message{ContentAvailable: true, Data: expo.Data{"sync": "1"}}},
+	{"ios_relevance_score", expo.Message{RelevanceScore: ptr(0.5)}},
+	{"ios_relevance_score_zero", expo.Message{RelevanceScore: ptr(0.0)}},
+	{"ios_target_content_id", expo.Message{TargetContentID: "window-1"}},
+	{"android_channel", expo.Message{ChannelID: "marketing"}},
+	{"android_icon", expo.Message{Icon: "ic_notification"}},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// TestGolden pins the JSON sent to Expo for every message field. Run with
+// -update to rewrite the golden files after an intended change, and compare
+// them against Expo's documentation.
+func TestGolden(t *testing.T) {
+	for _, tt := range goldenMessages {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			msg.To = []*expo.Token{expo.MustParseToken("ExponentPushToken[xxxxxxxxxxxxxxxxxxxxxx]")}
+			got, err := msg.MarshalExpoJSON()
+			if err != nil {
+				t.Fatalf("MarshalExpoJSON() = %v", err)
+			}
+
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, append(got, '\n'), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			if want = bytes.TrimSuffix(want, []byte("\n")); !bytes.Equal(got, want) {
+				t.Errorf("MarshalExpoJSON() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestGoldenCoversMessage fails when Message gains a field that no golden message sets
+func TestGoldenCoversMessage(t *testing.T) {
+	typ := reflect.TypeOf(expo.Message{})
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if field.Name == "To" {
+			continue
+		}
+		covered := slices.ContainsFunc(goldenMessages, func(tt goldenMessage) bool {
+			return !reflect.ValueOf(tt.msg).Field(i).IsZero()
+		})
+		if !covered {
+			t.Errorf("no golden message sets %s", field.Name)
+		}
+	}
+}
+
+// TestExtend checks the members added to objects, which MarshalJSON relies on
+func TestExtend(t *testing.T) {
+	tests := []struct {
+		obj     string
+		members []payload.Member
+		want    string
+	}{
+		{obj: `{}`, want: `{}`},
+		{obj: `{}`, members: []payload.Member{{Name: "a", Value: 1}}, want: `{"a":1}`},
+		{obj: `{"a":1}`, members: []payload.Member{{Name: "b", Value: "x"}, {Name: "c", Value: nil}}, want: `{"a":1,"b":"x","c":null}`},
+		{obj: `{"a":{"b":1}}`, members: []payload.Member{{Name: "c", Value: []int{1}}}, want: `{"a":{"b":1},"c":[1]}`},
+	}
+	for _, tt := range tests {
+		got, err := payload.Extend([]byte(tt.obj), tt.members...)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Extend(%s, %v) = %s, %v, want %s", tt.obj, tt.members, got, err, tt.want)
+		}
+	}
+
+	for _, obj := range []string{``, `[]`, `null`, `"{}"`} {
+		if _, err := payload.Extend([]byte(obj), payload.Member{Name: "a", Value: 1}); err == nil {
+			t.Errorf("Extend(%q) succeeded", obj)
+		}
+	}
+	if _, err := payload.Extend([]byte(`{}`), payload.Member{Name: "f", Value: func() {}}); err == nil {
+		t.Error("Extend() of an unencodable value succeeded")
+	}
+}
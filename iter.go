@@ -0,0 +1,131 @@
+package expo
+
+import (
+	"context"
+	"iter"
+)
+
+// PublishSeq returns an iterator that publishes msgs lazily, in requests of
+// as many recipients as fit, and yields every push ticket with the message
+// it belongs to. Messages are pulled from msgs only as the iteration needs
+// them, and a message with more recipients than a request holds is split.
+// A request that fails yields responses carrying its error in Err for each
+// recipient, and iteration continues with the next messages; a nil message
+// yields a single such response. Use slices.Values to publish a slice.
+func (c *Client) PublishSeq(ctx context.Context, msgs iter.Seq[*Message]) iter.Seq2[*Message, *MessageResponse] {
+	return func(yield func(*Message, *MessageResponse) bool) {
+		size := c.maxBatchSize()
+		var chunk []*Message
+		var recipients int
+		// originals maps the parts of split messages to the message they were split from
+		originals := make(map[*Message]*Message)
+
+		flush := func() bool {
+			if len(chunk) == 0 {
+				return true
+			}
+			responses, err := c.Publish(ctx, chunk)
+			if err != nil {
+				responses = failedResponses(chunk, err)
+			}
+			for _, response := range responses {
+				if original, ok := originals[response.MessageItem]; ok {
+					response.MessageItem = original
+				}
+				if !yield(response.MessageItem, response) {
+					return false
+				}
+			}
+			chunk, recipients = nil, 0
+			clear(originals)
+			return true
+		}
+
+		index := -1
+		for msg := range msgs {
+			index++
+			if msg == nil {
+				// A nil message would fail the request of the messages around it
+				err := ValidationIssue{Index: index, Field: "message", Severity: SeverityError, Message: "missing message"}
+				if !yield(nil, &MessageResponse{Status: "error", Message: err.Error(), Err: err}) {
+					return
+				}
+				continue
+			}
+			parts := []*Message{msg}
+			if len(msg.To) > size {
+				parts = SplitRecipients(msg, size)
+			}
+			for _, part := range parts {
+				// Messages without recipients still take a place in the request
+				weight := max(len(part.To), 1)
+				if recipients+weight > size && !flush() {
+					return
+				}
+				if part != msg {
+					originals[part] = msg
+				}
+				chunk = append(chunk, part)
+				recipients += weight
+			}
+		}
+		flush()
+	}
+}
+
+// failedResponses returns a response carrying err for every recipient of msgs
+func failedResponses(msgs []*Message, err error) []*MessageResponse {
+	var responses []*MessageResponse
+	for _, msg := range msgs {
+		for _, token := range msg.To {
+			responses = append(responses, &MessageResponse{
+				MessageItem: msg,
+				Token:       token,
+				Status:      "error",
+				Message:     err.Error(),
+				Err:         err,
+			})
+		}
+	}
+	return responses
+}
+
+// GetPushReceiptsSeq returns an iterator that fetches the receipts of ids
+// lazily, in requests of as many IDs as fit, and yields each receipt found
+// with its ticket ID. IDs without a receipt yet are skipped. Iteration stops
+// at the first failed request; the returned func reports its error once the
+// iteration is over.
+func (c *Client) GetPushReceiptsSeq(ctx context.Context, ids iter.Seq[string]) (iter.Seq2[string, *PushReceipt], func() error) {
+	var err error
+	seq := func(yield func(string, *PushReceipt) bool) {
+		size := c.maxReceiptBatch()
+		var chunk []string
+
+		flush := func() bool {
+			if len(chunk) == 0 {
+				return true
+			}
+			var receipts map[string]*PushReceipt
+			receipts, err = c.GetPushReceipts(ctx, chunk)
+			if err != nil {
+				return false
+			}
+			for _, id := range chunk {
+				if receipt, ok := receipts[id]; ok && !yield(id, receipt) {
+					return false
+				}
+			}
+			chunk = chunk[:0]
+			return true
+		}
+
+		for id := range ids {
+			chunk = append(chunk, id)
+			if len(chunk) == size && !flush() {
+				return
+			}
+		}
+		flush()
+	}
+	return seq, func() error { return err }
+}
@@ -0,0 +1,104 @@
+package expo
+
+import (
+	"context"
+	"sync"
+)
+
+// Lane is the priority with which a BatchSender publishes a batch. When all
+// of a sender's concurrency slots are busy, chunks waiting in a higher lane
+// get the next free slot before any chunk of a lower lane.
+type Lane int
+
+const (
+	// LaneNormal is the lane used by Send
+	LaneNormal Lane = iota
+	// LaneHigh is for transactional notifications such as one-time codes and alerts
+	LaneHigh
+	// LaneLow is for bulk campaigns that may wait behind everything else
+	LaneLow
+)
+
+// lanePriority lists the lanes from highest to lowest priority
+var lanePriority = []Lane{LaneHigh, LaneNormal, LaneLow}
+
+func (l Lane) String() string {
+	switch l {
+	case LaneNormal:
+		return "normal"
+	case LaneHigh:
+		return "high"
+	case LaneLow:
+		return "low"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// MetricBatchChunks counts chunks published by a BatchSender, labeled by lane and status
+	MetricBatchChunks = "expo_batch_chunks_total"
+	// MetricLaneWait measures how long chunks waited for a concurrency slot, labeled by lane
+	MetricLaneWait = "expo_lane_wait_seconds"
+)
+
+// laneLimiter hands out a fixed number of slots, serving waiters of higher
+// lanes first and waiters of the same lane in order
+type laneLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	active  int
+	waiters map[Lane][]chan struct{}
+}
+
+func newLaneLimiter(limit int) *laneLimiter {
+	return &laneLimiter{limit: limit, waiters: make(map[Lane][]chan struct{})}
+}
+
+// acquire blocks until a slot is free for lane or ctx is done
+func (l *laneLimiter) acquire(ctx context.Context, lane Lane) error {
+	if lane.String() == "unknown" {
+		lane = LaneNormal
+	}
+	l.mu.Lock()
+	if l.active < l.limit {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{}, 1)
+	l.waiters[lane] = append(l.waiters[lane], ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		for i, w := range l.waiters[lane] {
+			if w == ready {
+				l.waiters[lane] = append(l.waiters[lane][:i], l.waiters[lane][i+1:]...)
+				l.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		l.mu.Unlock()
+		// The slot was handed over while giving up; pass it on
+		l.release()
+		return ctx.Err()
+	}
+}
+
+// release frees a slot, handing it directly to the highest priority waiter
+func (l *laneLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, lane := range lanePriority {
+		if waiters := l.waiters[lane]; len(waiters) > 0 {
+			l.waiters[lane] = waiters[1:]
+			waiters[0] <- struct{}{}
+			return
+		}
+	}
+	l.active--
+}
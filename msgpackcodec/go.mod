@@ -0,0 +1,10 @@
+module dezeto/expo-push-notification/msgpackcodec
+
+go 1.23.10
+
+require (
+	dezeto/expo-push-notification v0.0.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,70 @@
+// Package msgpackcodec provides an expo.Codec encoding values as MessagePack,
+// which makes queue files and stored tickets smaller and faster to decode
+// than JSON. It is a separate module to keep the MessagePack dependency out
+// of the main package.
+package msgpackcodec
+
+import (
+	"bytes"
+	"encoding/json"
+
+	expo "dezeto/expo-push-notification"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes values as MessagePack. Values are converted through their
+// JSON encoding, so types with their own JSON encoding, such as expo.Message
+// with its Extra fields, keep every field, and the MessagePack document has
+// the structure of the JSON one.
+var Codec expo.Codec = codec{}
+
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return msgpack.Marshal(fromJSON(doc))
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	var doc any
+	if err := msgpack.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	jsonData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(jsonData, v)
+}
+
+// fromJSON replaces the json.Numbers in a decoded JSON document by integers
+// where they are whole, so that they are encoded compactly and exactly
+func fromJSON(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for key, value := range v {
+			v[key] = fromJSON(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = fromJSON(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return doc
+}
@@ -0,0 +1,66 @@
+package msgpackcodec_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/msgpackcodec"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	msgs := []*expo.Message{
+		{
+			To:         []*expo.Token{expo.MustParseToken("ExponentPushToken[token-1]")},
+			Title:      "Title",
+			Body:       "Body",
+			Data:       expo.Data{"id": "42"},
+			TTL:        3600,
+			ClearBadge: true,
+			Extra:      map[string]any{"futureField": "value", "futureCount": 3.5},
+		},
+		{
+			To:    []*expo.Token{expo.MustParseToken("ExponentPushToken[token-2]")},
+			Badge: 7,
+		},
+	}
+
+	data, err := msgpackcodec.Codec.Marshal(msgs)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	if json.Valid(data) {
+		t.Fatalf("Marshal() returned JSON: %s", data)
+	}
+	var got []*expo.Message
+	if err := msgpackcodec.Codec.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+
+	want, _ := json.Marshal(msgs)
+	gotJSON, _ := json.Marshal(got)
+	if string(gotJSON) != string(want) {
+		t.Errorf("round trip = %s, want %s", gotJSON, want)
+	}
+	if !reflect.DeepEqual(got[0].Extra, msgs[0].Extra) || !got[0].ClearBadge {
+		t.Errorf("round trip lost Extra or ClearBadge: %+v", got[0])
+	}
+}
+
+func TestCodecSmallerThanJSON(t *testing.T) {
+	msg := &expo.Message{
+		To:       []*expo.Token{expo.MustParseToken("ExponentPushToken[token-1]")},
+		Body:     "Body",
+		TTL:      86400,
+		Priority: "high",
+	}
+	data, err := msgpackcodec.Codec.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	jsonData, _ := expo.JSONCodec.Marshal(msg)
+	if len(data) >= len(jsonData) {
+		t.Errorf("MessagePack encoding is %d bytes, JSON %d", len(data), len(jsonData))
+	}
+}
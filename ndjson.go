@@ -0,0 +1,175 @@
+package expo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// UnmarshalJSON decodes a message, accepting "to" as either a single token or
+// an array of tokens like the Expo API does. Unknown fields are kept in Extra,
+// and a badge of 0 sets ClearBadge.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	aux := struct {
+		*message
+		To json.RawMessage `json:"to"`
+	}{message: (*message)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	extra, err := extraFields(data)
+	if err != nil {
+		return err
+	}
+	m.Extra = extra
+
+	var badge struct {
+		Badge *int `json:"badge"`
+	}
+	if err := json.Unmarshal(data, &badge); err == nil && badge.Badge != nil {
+		m.ClearBadge = *badge.Badge == 0
+	}
+
+	m.To = nil
+	if len(aux.To) == 0 || string(aux.To) == "null" {
+		return nil
+	}
+	var single Token
+	if err := json.Unmarshal(aux.To, &single); err == nil {
+		m.To = []*Token{&single}
+		return nil
+	}
+	if err := json.Unmarshal(aux.To, &m.To); err != nil {
+		return fmt.Errorf("invalid recipients: %w", err)
+	}
+	return nil
+}
+
+// DecodeMessages reads messages from r, given either as a JSON array or as
+// newline-delimited JSON objects. Recipients may be a single token string or an
+// array of them; tokens are not validated.
+func DecodeMessages(r io.Reader) ([]*Message, error) {
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(br)
+	if first == '[' {
+		var msgs []*Message
+		if err := dec.Decode(&msgs); err != nil {
+			return nil, err
+		}
+		return msgs, nil
+	}
+
+	var msgs []*Message
+	for {
+		var msg Message
+		err := dec.Decode(&msg)
+		if errors.Is(err, io.EOF) {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", len(msgs), err)
+		}
+		msgs = append(msgs, &msg)
+	}
+}
+
+// peekNonSpace skips leading whitespace and returns the next byte without consuming it
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+// ResultRecord is the flattened form of a PushResult written by EncodeResults
+type ResultRecord struct {
+	TicketID       string      `json:"ticketId,omitempty"`
+	Token          string      `json:"token,omitempty"`
+	Classification ResultClass `json:"classification"`
+	ErrorCode      string      `json:"errorCode,omitempty"`
+	Error          string      `json:"error,omitempty"`
+	TicketStatus   string      `json:"ticketStatus,omitempty"`
+	TicketMessage  string      `json:"ticketMessage,omitempty"`
+	ReceiptStatus  string      `json:"receiptStatus,omitempty"`
+	ReceiptMessage string      `json:"receiptMessage,omitempty"`
+	Attempts       int         `json:"attempts,omitempty"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	// Meta is the Meta of the message
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// NewResultRecord flattens a PushResult
+func NewResultRecord(result *PushResult) *ResultRecord {
+	record := &ResultRecord{
+		TicketID:       result.TicketID,
+		Classification: result.Classify(),
+		ErrorCode:      result.ErrorCode(),
+		Attempts:       result.Attempts,
+		CreatedAt:      result.CreatedAt,
+		Meta:           result.Meta,
+	}
+	if result.Token != nil {
+		record.Token = string(*result.Token)
+	}
+	if result.Error != nil {
+		record.Error = result.Error.Error()
+	}
+	if result.PushTicket != nil {
+		record.TicketStatus = result.PushTicket.Status
+		record.TicketMessage = result.PushTicket.Message
+	}
+	if result.PushReceipt != nil {
+		record.ReceiptStatus = result.PushReceipt.Status
+		record.ReceiptMessage = result.PushReceipt.Message
+	}
+	return record
+}
+
+// EncodeResults writes one flattened ResultRecord per line to w, suitable for
+// loading into analytics systems
+func EncodeResults(w io.Writer, results []*PushResult) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, result := range results {
+		if err := enc.Encode(NewResultRecord(result)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// DecodeResults reads the records written by EncodeResults
+func DecodeResults(r io.Reader) ([]*ResultRecord, error) {
+	dec := json.NewDecoder(r)
+	var records []*ResultRecord
+	for {
+		var record ResultRecord
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records), err)
+		}
+		records = append(records, &record)
+	}
+}
@@ -0,0 +1,37 @@
+package expo
+
+// NewAlert creates a notification shown to the user, delivered with high
+// priority and the default sound
+func NewAlert(title, body string, tokens ...*Token) *Message {
+	return &Message{
+		To:       tokens,
+		Title:    title,
+		Body:     body,
+		Sound:    "default",
+		Priority: HighPriority,
+	}
+}
+
+// NewSilentDataPush creates a notification that shows nothing and wakes the
+// app in the background to handle data. It has no title, body or sound, and is
+// sent with normal priority, as iOS does not deliver background notifications
+// sent with high priority reliably.
+func NewSilentDataPush(data Data, tokens ...*Token) *Message {
+	return &Message{
+		To:               tokens,
+		Data:             data,
+		ContentAvailable: true,
+		Priority:         NormalPriority,
+	}
+}
+
+// NewBadgeUpdate creates a notification that only sets the iOS app badge to n;
+// zero clears it
+func NewBadgeUpdate(n int, tokens ...*Token) *Message {
+	return &Message{
+		To:         tokens,
+		Badge:      n,
+		ClearBadge: n == 0,
+		Priority:   NormalPriority,
+	}
+}
@@ -0,0 +1,67 @@
+package expo_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestDataOnlyPayload(t *testing.T) {
+	data := expo.Data{"sync": "inbox"}
+	tests := []struct {
+		name                 string
+		msg                  *expo.Message
+		wantContentAvailable bool
+	}{
+		{name: "silent data push", msg: expo.NewSilentDataPush(data, testToken(0)), wantContentAvailable: true},
+		{name: "data only", msg: &expo.Message{To: []*expo.Token{testToken(0)}, Data: data}},
+		{name: "empty strings", msg: &expo.Message{To: []*expo.Token{testToken(0)}, Data: data, Title: "", Body: "", Sound: ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := tt.msg.MarshalExpoJSON()
+			if err != nil {
+				t.Fatalf("MarshalExpoJSON() = %v", err)
+			}
+			var fields map[string]any
+			if err := json.Unmarshal(payload, &fields); err != nil {
+				t.Fatal(err)
+			}
+			checkDataOnly(t, fields, tt.wantContentAvailable)
+		})
+	}
+}
+
+// TestSilentDataPushSent checks the payload of a silent push as Expo receives it
+func TestSilentDataPushSent(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) { okTickets(t, w, req) })
+	client, _ := newTestClient(s)
+
+	if _, err := client.PublishSingle(context.Background(), expo.NewSilentDataPush(expo.Data{"sync": "inbox"}, testToken(0))); err != nil {
+		t.Fatalf("PublishSingle() = %v", err)
+	}
+	msgs := sentMessages(t, s.Requests()[0].Body)
+	checkDataOnly(t, msgs[0], true)
+	if msgs[0]["priority"] != string(expo.NormalPriority) {
+		t.Errorf("priority = %v, want %q", msgs[0]["priority"], expo.NormalPriority)
+	}
+}
+
+// checkDataOnly checks that a message payload carries its data and nothing to display
+func checkDataOnly(t *testing.T, fields map[string]any, wantContentAvailable bool) {
+	t.Helper()
+	for _, name := range []string{"title", "body", "sound", "subtitle", "badge"} {
+		if value, ok := fields[name]; ok {
+			t.Errorf("payload has %q = %v", name, value)
+		}
+	}
+	if data, _ := fields["data"].(map[string]any); data["sync"] != "inbox" {
+		t.Errorf("data = %v", fields["data"])
+	}
+	if got, ok := fields["_contentAvailable"]; ok != wantContentAvailable || (ok && got != true) {
+		t.Errorf("_contentAvailable = %v, present %t, want present %t", got, ok, wantContentAvailable)
+	}
+}
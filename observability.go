@@ -0,0 +1,109 @@
+package expo
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// LogLevel is the severity of a log entry
+type LogLevel int
+
+// Log levels, from least to most severe
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Logger receives diagnostic messages from the client. Fields include the
+// call metadata attached to the context with WithCallMetadata. It is called
+// from concurrent goroutines and must be safe for concurrent use.
+type Logger interface {
+	Log(level LogLevel, msg string, fields map[string]string)
+}
+
+// Metrics receives measurements from the client. Labels include the call
+// metadata attached to the context with WithCallMetadata. It is called from
+// concurrent goroutines and must be safe for concurrent use.
+type Metrics interface {
+	IncCounter(name string, value float64, labels map[string]string)
+	ObserveDuration(name string, d time.Duration, labels map[string]string)
+}
+
+const (
+	// MetricRequests counts HTTP requests to Expo, labeled by endpoint and status
+	MetricRequests = "expo_requests_total"
+	// MetricRequestDuration measures HTTP requests to Expo, labeled by endpoint and status
+	MetricRequestDuration = "expo_request_duration_seconds"
+	// MetricRetries counts retried HTTP requests
+	MetricRetries = "expo_retries_total"
+)
+
+type callMetadataKey struct{}
+
+// WithCallMetadata returns a copy of ctx carrying metadata such as tenant or
+// campaign IDs. The client passes it to the Logger, Metrics, and ResultSink of
+// every call made with the context; sinks read it with CallMetadata.
+func WithCallMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	merged := maps.Clone(CallMetadata(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(metadata))
+	}
+	maps.Copy(merged, metadata)
+	return context.WithValue(ctx, callMetadataKey{}, merged)
+}
+
+// CallMetadata returns the metadata attached to ctx with WithCallMetadata, or nil
+func CallMetadata(ctx context.Context) map[string]string {
+	metadata, _ := ctx.Value(callMetadataKey{}).(map[string]string)
+	return metadata
+}
+
+// withMetadata merges the call metadata of ctx into a copy of fields; fields win on conflict
+func withMetadata(ctx context.Context, fields map[string]string) map[string]string {
+	merged := maps.Clone(CallMetadata(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(fields))
+	}
+	maps.Copy(merged, fields)
+	return merged
+}
+
+func (c *Client) log(ctx context.Context, level LogLevel, msg string, fields map[string]string) {
+	if c.cnf.Logger != nil {
+		fields = withMetadata(ctx, fields)
+		for name, value := range fields {
+			fields[name] = c.redact(ctx, value)
+		}
+		c.cnf.Logger.Log(level, c.redact(ctx, msg), fields)
+	}
+}
+
+func (c *Client) incCounter(ctx context.Context, name string, value float64, labels map[string]string) {
+	if c.cnf.Metrics != nil {
+		c.cnf.Metrics.IncCounter(name, value, withMetadata(ctx, labels))
+	}
+}
+
+func (c *Client) observeDuration(ctx context.Context, name string, d time.Duration, labels map[string]string) {
+	if c.cnf.Metrics != nil {
+		c.cnf.Metrics.ObserveDuration(name, d, withMetadata(ctx, labels))
+	}
+}
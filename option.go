@@ -1,14 +1,95 @@
 package expo
 
-import "net/http"
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
 
 type Config struct {
-	Host        string
-	ApiURL      string
-	AccessToken string
-	HttpClient  *http.Client
-	EnableGzip  bool
+	Host   string
+	ApiURL string
+	// SendEndpoint and ReceiptsEndpoint override the path, or full URL, of the endpoints
+	SendEndpoint     string
+	ReceiptsEndpoint string
+	AccessToken      string
+	HttpClient       *http.Client
+	EnableGzip       bool
+	// TLSConfig and ForceHTTP2 are applied to a copy of the HTTP client's transport
+	TLSConfig  *tls.Config
+	ForceHTTP2 bool
+	// GzipThreshold is the minimum request body size in bytes that gets compressed
+	GzipThreshold int
+	// StreamBody encodes request bodies on the fly instead of buffering them
+	StreamBody  bool
 	RetryConfig *RetryConfig
+	// ReceiptSchedule controls when the workflow and ReceiptTracker check receipts
+	ReceiptSchedule *ReceiptSchedule
+	// ReceiptRetry controls resending recipients whose receipts report retryable errors
+	ReceiptRetry *RetryConfig
+	// ReceiptFetchOnCancel is how long a canceled workflow may spend fetching receipts; zero disables it
+	ReceiptFetchOnCancel time.Duration
+	// RetryBudget is shared by every call made with the client
+	RetryBudget *RetryBudget
+	// HedgeDelay is how long a publish attempt may stay unanswered before it is hedged
+	HedgeDelay time.Duration
+	// MaxHedges is the number of extra attempts fired per publish; zero disables hedging
+	MaxHedges int
+	// MaxConcurrentRequests caps the HTTP requests in flight at once; zero means no limit
+	MaxConcurrentRequests int
+	// MaxBatchSize and MaxReceiptBatch lower the messages per publish request and
+	// the ticket IDs per receipts request; zero uses Expo's limits of 100 and 1000
+	MaxBatchSize    int
+	MaxReceiptBatch int
+	// AdaptiveRate enables publish rate limiting adjusted by rate limiting reported by Expo
+	AdaptiveRate *AdaptiveRateConfig
+	// AutoBatchDelay and AutoBatchSize configure coalescing of small Publish calls
+	AutoBatchDelay time.Duration
+	AutoBatchSize  int
+	ResultSink     ResultSink
+	// ValidationMode controls how messages are validated before publishing
+	ValidationMode ValidationMode
+	// MaxPayloadBytes is the largest serialized message validation accepts;
+	// zero checks an estimate against Expo's limit instead
+	MaxPayloadBytes int
+	// DecodeMode controls whether responses may contain unknown fields
+	DecodeMode DecodeMode
+	// DedupeRecipients removes repeated tokens within each message before publishing
+	DedupeRecipients bool
+	// DedupeAcrossBatch also removes tokens already addressed by an earlier message of the same call
+	DedupeAcrossBatch bool
+	// DedupeStore remembers sent notifications for DedupeTTL to make publishing idempotent
+	DedupeStore DedupeStore
+	DedupeTTL   time.Duration
+	// Categories are the notification categories messages may refer to
+	Categories map[string]*NotificationCategory
+	// DebugDump receives sanitized copies of every request and response
+	DebugDump io.Writer
+	Logger    Logger
+	Metrics   Metrics
+	Clock     Clock
+	// OnCall is called with the details of every response received from the API
+	OnCall func(ctx context.Context, info *CallInfo)
+	// Authorizer is consulted before each message is sent
+	Authorizer Authorizer
+	// TokenResolver looks up user tokens for PublishToUsers
+	TokenResolver TokenResolver
+	// FallbackProvider receives the recipients PublishPartitioned cannot send to Expo
+	FallbackProvider FallbackProvider
+	// TokenRepository receives the unregistered tokens found by the workflow
+	TokenRepository TokenRepository
+	// WorkflowStore keeps the outstanding tickets of workflows given an ID with WithWorkflowID
+	WorkflowStore TicketStore
+	// CrashOnPanic lets panics in background work crash the process instead of being recovered
+	CrashOnPanic bool
 }
 
 type Option func(*Config)
@@ -25,15 +106,52 @@ func WithApiURL(apiURL string) Option {
 	}
 }
 
+// WithSendEndpoint overrides the publish endpoint, which defaults to ApiURL
+// followed by /push/send. A path is resolved against the host; a full URL
+// such as https://relay.example.com/send is used as is.
+func WithSendEndpoint(endpoint string) Option {
+	return func(c *Config) {
+		c.SendEndpoint = endpoint
+	}
+}
+
+// WithReceiptsEndpoint overrides the receipts endpoint, which defaults to
+// ApiURL followed by /push/getReceipts, in the same way as WithSendEndpoint
+func WithReceiptsEndpoint(endpoint string) Option {
+	return func(c *Config) {
+		c.ReceiptsEndpoint = endpoint
+	}
+}
+
 func WithAccessToken(accessToken string) Option {
 	return func(c *Config) {
 		c.AccessToken = accessToken
 	}
 }
 
+// WithGzipEnabled enables gzip compression for every request body.
+//
+// Deprecated: use WithGzipThreshold, which skips compression for small payloads.
 func WithGzipEnabled(enabled bool) Option {
 	return func(c *Config) {
 		c.EnableGzip = enabled
+		c.GzipThreshold = 0
+	}
+}
+
+// WithGzipThreshold compresses request bodies whose marshaled size is at least minBytes
+func WithGzipThreshold(minBytes int) Option {
+	return func(c *Config) {
+		c.EnableGzip = true
+		c.GzipThreshold = minBytes
+	}
+}
+
+// WithStreamingBody streams the JSON (and gzip) encoding of requests
+// directly into the connection, halving peak memory for large batches
+func WithStreamingBody(enabled bool) Option {
+	return func(c *Config) {
+		c.StreamBody = enabled
 	}
 }
 
@@ -43,12 +161,285 @@ func WithRetryConfig(retryConfig *RetryConfig) Option {
 	}
 }
 
+// WithReceiptRetry makes SendPushNotificationsWithReceipts resend recipients
+// whose receipts report a retryable error such as MessageRateExceeded. Up to
+// MaxRetries passes are made, each after an exponentially increasing delay.
+func WithReceiptRetry(retryConfig *RetryConfig) Option {
+	return func(c *Config) {
+		c.ReceiptRetry = retryConfig
+	}
+}
+
+// WithReceiptSchedule sets when receipts are checked: by
+// SendPushNotificationsWithReceipts when called with a zero delay, and by
+// ReceiptTracker.Check. See DefaultReceiptSchedule for Expo's recommendation.
+func WithReceiptSchedule(schedule *ReceiptSchedule) Option {
+	return func(c *Config) {
+		c.ReceiptSchedule = schedule
+	}
+}
+
+// WithRetryBudget limits the retries made across all calls of the client
+func WithRetryBudget(budget *RetryBudget) Option {
+	return func(c *Config) {
+		c.RetryBudget = budget
+	}
+}
+
+// WithHedging fires up to maxHedges duplicate publish requests, each after delay
+// without a response, and uses whichever answers first. This trades possible
+// duplicate notifications for lower tail latency.
+func WithHedging(delay time.Duration, maxHedges int) Option {
+	return func(c *Config) {
+		c.HedgeDelay = delay
+		c.MaxHedges = maxHedges
+	}
+}
+
+// WithReceiptFetchOnCancel makes SendPushNotificationsWithReceipts fetch the
+// receipts available so far, for up to timeout, when its context is done while
+// it waits for them. Accepted tickets whose receipt is still unknown then
+// report ErrReceiptUnknown instead of no receipt and no error.
+func WithReceiptFetchOnCancel(timeout time.Duration) Option {
+	return func(c *Config) {
+		c.ReceiptFetchOnCancel = timeout
+	}
+}
+
+// WithMaxConcurrentRequests limits the number of HTTP requests the client has
+// in flight at once, across all goroutines. Further calls wait for a free slot
+// or for their context to be done. A request stays in flight until its
+// response body has been read.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(c *Config) {
+		c.MaxConcurrentRequests = n
+	}
+}
+
+// WithAutoBatch coalesces Publish and PublishSingle calls made within maxDelay
+// of each other into combined requests of up to maxSize messages (at most 100).
+// Each caller still gets the responses for its own messages, at the cost of up
+// to maxDelay of added latency. Calls with maxSize messages or more are sent
+// directly.
+func WithAutoBatch(maxDelay time.Duration, maxSize int) Option {
+	return func(c *Config) {
+		c.AutoBatchDelay = maxDelay
+		c.AutoBatchSize = maxSize
+	}
+}
+
+// WithAdaptiveRateLimit spaces publish requests at a rate that is lowered
+// when Expo reports rate limiting and raised again while it does not. A nil
+// cnf uses DefaultAdaptiveRateConfig.
+func WithAdaptiveRateLimit(cnf *AdaptiveRateConfig) Option {
+	return func(c *Config) {
+		if cnf == nil {
+			cnf = DefaultAdaptiveRateConfig()
+		}
+		c.AdaptiveRate = cnf
+	}
+}
+
+// WithMaxBatchSize caps the messages sent in one publish request at n, for
+// proxies that limit request size or to lower the latency of each request.
+// It cannot exceed Expo's limit of 100.
+func WithMaxBatchSize(n int) Option {
+	return func(c *Config) {
+		c.MaxBatchSize = n
+	}
+}
+
+// WithMaxReceiptBatch caps the ticket IDs queried in one receipts request at n.
+// It cannot exceed Expo's limit of 1000.
+func WithMaxReceiptBatch(n int) Option {
+	return func(c *Config) {
+		c.MaxReceiptBatch = n
+	}
+}
+
+// WithMaxPayloadBytes makes validation reject messages whose serialized size,
+// as returned by Message.PayloadSize, exceeds n bytes, and warn about messages
+// within 1/8 of it. Use it when a proxy in front of Expo enforces a lower limit.
+func WithMaxPayloadBytes(n int) Option {
+	return func(c *Config) {
+		c.MaxPayloadBytes = n
+	}
+}
+
+// WithValidation sets how messages are validated before publishing.
+// The default is ValidationBasic.
+func WithValidation(mode ValidationMode) Option {
+	return func(c *Config) {
+		c.ValidationMode = mode
+	}
+}
+
+// WithDecodeMode sets how responses with fields unknown to this package are
+// handled. The default, DecodeTolerant, keeps them available in Raw.
+func WithDecodeMode(mode DecodeMode) Option {
+	return func(c *Config) {
+		c.DecodeMode = mode
+	}
+}
+
+// WithDeduplicateRecipients removes repeated tokens within each message before publishing
+func WithDeduplicateRecipients(enabled bool) Option {
+	return func(c *Config) {
+		c.DedupeRecipients = enabled
+	}
+}
+
+// WithDeduplicateAcrossBatch removes tokens already addressed by an earlier
+// message of the same Publish call, in addition to duplicates within a message
+func WithDeduplicateAcrossBatch(enabled bool) Option {
+	return func(c *Config) {
+		c.DedupeRecipients = c.DedupeRecipients || enabled
+		c.DedupeAcrossBatch = enabled
+	}
+}
+
+// WithDedupeStore skips recipients that already received the same message
+// within ttl, as recorded in store. Sharing the store between instances makes
+// publishing idempotent across them.
+func WithDedupeStore(store DedupeStore, ttl time.Duration) Option {
+	return func(c *Config) {
+		c.DedupeStore = store
+		c.DedupeTTL = ttl
+	}
+}
+
+// WithCategories defines the notification categories known to the app. In strict
+// validation mode, messages referring to any other CategoryID are rejected.
+func WithCategories(categories ...*NotificationCategory) Option {
+	return func(c *Config) {
+		if c.Categories == nil {
+			c.Categories = make(map[string]*NotificationCategory, len(categories))
+		}
+		for _, category := range categories {
+			c.Categories[category.Identifier] = category
+		}
+	}
+}
+
+// WithDebugDump writes every request and response body to w, decompressed and
+// with the access token redacted, to help diagnose payload issues
+func WithDebugDump(w io.Writer) Option {
+	return func(c *Config) {
+		c.DebugDump = w
+	}
+}
+
+// WithLogger sets a logger for diagnostic messages such as retries
+func WithLogger(logger Logger) Option {
+	return func(c *Config) {
+		c.Logger = logger
+	}
+}
+
+// WithMetrics sets a receiver for request and retry measurements
+func WithMetrics(metrics Metrics) Option {
+	return func(c *Config) {
+		c.Metrics = metrics
+	}
+}
+
+// WithCallInfo calls fn with the request ID, rate limit headers and latency of
+// every response received from the API, including retried and hedged attempts
+func WithCallInfo(fn func(ctx context.Context, info *CallInfo)) Option {
+	return func(c *Config) {
+		c.OnCall = fn
+	}
+}
+
+// WithTokenResolver sets the resolver used by PublishToUsers
+func WithTokenResolver(resolver TokenResolver) Option {
+	return func(c *Config) {
+		c.TokenResolver = resolver
+	}
+}
+
+// WithFallbackProvider routes the recipients PublishPartitioned finds without
+// an Expo push token, such as raw APNs or FCM tokens, to provider
+func WithFallbackProvider(provider FallbackProvider) Option {
+	return func(c *Config) {
+		c.FallbackProvider = provider
+	}
+}
+
+// WithAutoTokenCleanup makes SendPushNotificationsWithReceipts delete the tokens
+// reported as DeviceNotRegistered from repo once the workflow has finished
+func WithAutoTokenCleanup(repo TokenRepository) Option {
+	return func(c *Config) {
+		c.TokenRepository = repo
+	}
+}
+
+// WithWorkflowStore persists the outstanding tickets of workflows run with a
+// context from WithWorkflowID in store, so that ResumeWorkflow can continue
+// checking their receipts after a restart
+func WithWorkflowStore(store TicketStore) Option {
+	return func(c *Config) {
+		c.WorkflowStore = store
+	}
+}
+
+// WithCrashOnPanic lets panics in background goroutines, such as BatchSender
+// workers and the ReceiptCursor loop, crash the process. By default they are
+// recovered, logged with their stack trace and reported as errors.
+func WithCrashOnPanic(enabled bool) Option {
+	return func(c *Config) {
+		c.CrashOnPanic = enabled
+	}
+}
+
+// WithAuthorizer checks every message before it is sent. Denied messages are
+// not sent and their recipients get responses carrying an AuthorizationError.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(c *Config) {
+		c.Authorizer = authorizer
+	}
+}
+
+// WithClock replaces the system clock, e.g. with a fake clock in tests
+func WithClock(clock Clock) Option {
+	return func(c *Config) {
+		c.Clock = clock
+	}
+}
+
 func WithHttpClient(httpClient *http.Client) Option {
 	return func(c *Config) {
 		c.HttpClient = httpClient
 	}
 }
 
+// WithTLSConfig sets the TLS configuration used to connect to Expo, e.g. to
+// trust a custom CA bundle or present a client certificate to an mTLS egress
+// proxy. It applies to the default HTTP client as well as one set with
+// WithHttpClient, whose transport is copied rather than modified.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = tlsConfig
+	}
+}
+
+// WithForceHTTP2 makes the client use HTTP/2 over TLS whenever the server
+// supports it, even with a custom TLS configuration or dialer, which would
+// otherwise fall back to HTTP/1.1. Like WithTLSConfig, it applies to a copy of
+// the HTTP client's transport.
+func WithForceHTTP2(enabled bool) Option {
+	return func(c *Config) {
+		c.ForceHTTP2 = enabled
+	}
+}
+
+// WithResultSink registers a sink that receives the results of every push workflow
+func WithResultSink(sink ResultSink) Option {
+	return func(c *Config) {
+		c.ResultSink = sink
+	}
+}
+
 func withDefaults(c *Config) {
 	if c.Host == "" {
 		c.Host = "https://exp.host"
@@ -59,7 +450,110 @@ func withDefaults(c *Config) {
 	if c.HttpClient == nil {
 		c.HttpClient = &http.Client{}
 	}
+	if c.TLSConfig != nil || c.ForceHTTP2 {
+		c.HttpClient = withTransportSettings(c.HttpClient, c.TLSConfig, c.ForceHTTP2)
+	}
 	if c.RetryConfig == nil {
 		c.RetryConfig = DefaultRetryConfig()
 	}
+	if c.Clock == nil {
+		c.Clock = SystemClock
+	}
+}
+
+// Validate checks the configuration for values that cannot work, such as a
+// malformed host or a negative retry count, and returns every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range joined.Unwrap() {
+				errs = append(errs, fmt.Errorf("%s: %w", field, err))
+			}
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	check("Host", validateBaseURL(c.Host))
+	if c.ApiURL != "" && !strings.HasPrefix(c.ApiURL, "/") {
+		check("ApiURL", fmt.Errorf("must start with '/', got %q", c.ApiURL))
+	}
+	if strings.Contains(c.SendEndpoint, "://") {
+		check("SendEndpoint", validateBaseURL(c.SendEndpoint))
+	}
+	if strings.Contains(c.ReceiptsEndpoint, "://") {
+		check("ReceiptsEndpoint", validateBaseURL(c.ReceiptsEndpoint))
+	}
+	if c.ReceiptFetchOnCancel < 0 {
+		check("ReceiptFetchOnCancel", fmt.Errorf("must not be negative, got %s", c.ReceiptFetchOnCancel))
+	}
+	if c.MaxPayloadBytes < 0 {
+		check("MaxPayloadBytes", fmt.Errorf("must not be negative, got %d", c.MaxPayloadBytes))
+	}
+	if c.TLSConfig != nil || c.ForceHTTP2 {
+		if _, ok := c.HttpClient.Transport.(*http.Transport); !ok && c.HttpClient.Transport != nil {
+			check("HttpClient", fmt.Errorf("TLSConfig and ForceHTTP2 need an *http.Transport, got %T", c.HttpClient.Transport))
+		}
+	}
+	if c.GzipThreshold < 0 {
+		check("GzipThreshold", fmt.Errorf("must not be negative, got %d", c.GzipThreshold))
+	}
+	if c.RetryConfig != nil {
+		check("RetryConfig", c.RetryConfig.Validate())
+	}
+	if c.ReceiptRetry != nil {
+		check("ReceiptRetry", c.ReceiptRetry.Validate())
+	}
+	if c.AdaptiveRate != nil {
+		check("AdaptiveRate", c.AdaptiveRate.Validate())
+	}
+	if s := c.ReceiptSchedule; s != nil && (s.FirstCheck < 0 || s.Interval < 0 || s.GiveUpAfter < 0) {
+		check("ReceiptSchedule", errors.New("durations must not be negative"))
+	}
+	if b := c.RetryBudget; b != nil && (b.MaxRetries < 0 || b.MinRetries < 0 || b.MaxRatio < 0 || b.Window < 0) {
+		check("RetryBudget", errors.New("limits must not be negative"))
+	}
+	if c.MaxConcurrentRequests < 0 {
+		check("MaxConcurrentRequests", fmt.Errorf("must not be negative, got %d", c.MaxConcurrentRequests))
+	}
+	if c.MaxBatchSize < 0 || c.MaxBatchSize > maxNotificationsPerRequest {
+		check("MaxBatchSize", fmt.Errorf("must be between 0 and %d, got %d", maxNotificationsPerRequest, c.MaxBatchSize))
+	}
+	if c.MaxReceiptBatch < 0 || c.MaxReceiptBatch > maxReceiptsPerRequest {
+		check("MaxReceiptBatch", fmt.Errorf("must be between 0 and %d, got %d", maxReceiptsPerRequest, c.MaxReceiptBatch))
+	}
+	if c.AutoBatchDelay < 0 || c.AutoBatchSize < 0 {
+		check("AutoBatch", errors.New("delay and size must not be negative"))
+	}
+	if c.MaxHedges < 0 {
+		check("MaxHedges", fmt.Errorf("must not be negative, got %d", c.MaxHedges))
+	} else if c.MaxHedges > 0 && c.HedgeDelay <= 0 {
+		check("HedgeDelay", fmt.Errorf("must be positive when hedging, got %s", c.HedgeDelay))
+	}
+	if c.ValidationMode < ValidationBasic || c.ValidationMode > ValidationOff {
+		check("ValidationMode", fmt.Errorf("unknown mode %d", c.ValidationMode))
+	}
+	if c.DedupeStore != nil && c.DedupeTTL <= 0 {
+		check("DedupeTTL", fmt.Errorf("must be positive when a DedupeStore is set, got %s", c.DedupeTTL))
+	}
+	for _, id := range slices.Sorted(maps.Keys(c.Categories)) {
+		check(fmt.Sprintf("Categories[%q]", id), c.Categories[id].Validate())
+	}
+	return errors.Join(errs...)
+}
+
+// validateBaseURL checks that raw is an absolute http or https URL
+func validateBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("must be an http or https URL, got %q", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host in %q", raw)
+	}
+	return nil
 }
@@ -0,0 +1,191 @@
+package expo
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// MetricUnsendableRecipients counts recipients left out of publish requests
+// by PublishPartitioned because their token is not an Expo push token, by kind
+const MetricUnsendableRecipients = "expo_unsendable_recipients_total"
+
+// TokenKind tells what kind of push token a string looks like
+type TokenKind int
+
+const (
+	TokenKindUnknown TokenKind = iota
+	// TokenKindExpo is an Expo push token, which Expo accepts
+	TokenKindExpo
+	// TokenKindAPNs looks like a raw APNs device token: 64 hex digits
+	TokenKindAPNs
+	// TokenKindFCM looks like a raw FCM registration token
+	TokenKindFCM
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenKindExpo:
+		return "expo"
+	case TokenKindAPNs:
+		return "apns"
+	case TokenKindFCM:
+		return "fcm"
+	}
+	return "unknown"
+}
+
+// ClassifyToken guesses the kind of token from its format. Raw device tokens
+// carry no marker, so APNs and FCM are told apart by shape only.
+func ClassifyToken(token string) TokenKind {
+	switch {
+	case IsPushTokenValid(token):
+		return TokenKindExpo
+	case len(token) == 64 && isHex(token):
+		return TokenKindAPNs
+	case len(token) >= 100 && strings.Contains(token, ":") && isTokenText(token):
+		return TokenKindFCM
+	}
+	return TokenKindUnknown
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// isTokenText reports whether s only holds the URL-safe base64 characters
+// and colons of FCM registration tokens
+func isTokenText(s string) bool {
+	for _, r := range s {
+		if !('0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '-' || r == '_' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+// UnsendableRecipient is a recipient Expo cannot deliver to, because its
+// token is not an Expo push token
+type UnsendableRecipient struct {
+	RemovedToken
+	// Kind is what the token looks like, for routing it to another provider
+	Kind TokenKind
+	// Message is the input message the recipient was taken from
+	Message *Message
+}
+
+// UnsendableReport lists the recipients PublishPartitioned did not send to Expo
+type UnsendableReport struct {
+	Recipients []UnsendableRecipient
+	// FallbackErr is the error of the FallbackProvider, if one is configured and failed
+	FallbackErr error
+}
+
+// Tokens returns the tokens of the recipients of the given kind; a nil report has none
+func (r *UnsendableReport) Tokens(kind TokenKind) []*Token {
+	if r == nil {
+		return nil
+	}
+	var tokens []*Token
+	for _, recipient := range r.Recipients {
+		if recipient.Kind == kind && recipient.Token != nil {
+			tokens = append(tokens, recipient.Token)
+		}
+	}
+	return tokens
+}
+
+// FallbackProvider delivers to recipients that are not reachable through
+// Expo, such as raw APNs or FCM tokens, e.g. by calling those services directly
+type FallbackProvider interface {
+	SendFallback(ctx context.Context, recipients []UnsendableRecipient) error
+}
+
+// FallbackProviderFunc adapts an ordinary function to the FallbackProvider interface
+type FallbackProviderFunc func(ctx context.Context, recipients []UnsendableRecipient) error
+
+// SendFallback calls f(ctx, recipients)
+func (f FallbackProviderFunc) SendFallback(ctx context.Context, recipients []UnsendableRecipient) error {
+	return f(ctx, recipients)
+}
+
+// PartitionTokens splits the recipients of messages into Expo push tokens and
+// the rest. It returns copies of the messages addressed to their Expo tokens
+// only, leaving out messages without any, and the other recipients. The input
+// is not modified and nil messages are skipped.
+func PartitionTokens(messages []*Message) ([]*Message, []UnsendableRecipient) {
+	sendable, unsendable, _ := partitionTokens(messages)
+	return sendable, unsendable
+}
+
+// partitionTokens is PartitionTokens that also maps each sendable copy to its input message
+func partitionTokens(messages []*Message) ([]*Message, []UnsendableRecipient, map[*Message]*Message) {
+	cleaned, removed := WithoutInvalidTokens(messages)
+
+	var sendable []*Message
+	originals := make(map[*Message]*Message, len(cleaned))
+	for i, msg := range cleaned {
+		if msg != nil && len(msg.To) > 0 {
+			sendable = append(sendable, msg)
+			originals[msg] = messages[i]
+		}
+	}
+
+	unsendable := make([]UnsendableRecipient, 0, len(removed))
+	for _, r := range removed {
+		kind := TokenKindUnknown
+		if r.Token != nil {
+			kind = ClassifyToken(string(*r.Token))
+		}
+		unsendable = append(unsendable, UnsendableRecipient{RemovedToken: r, Kind: kind, Message: messages[r.MessageIndex]})
+	}
+	return sendable, unsendable, originals
+}
+
+// PublishPartitioned sends msgs to their Expo push tokens and reports the
+// other recipients instead of failing the whole call on them. If a
+// FallbackProvider is configured, it receives those recipients; its failure
+// is reported in FallbackErr rather than returned. The error is that of the
+// publish request, and the report is returned with it.
+func (c *Client) PublishPartitioned(ctx context.Context, msgs []*Message) ([]*MessageResponse, *UnsendableReport, error) {
+	if err := c.checkNil(msgs); err != nil {
+		return nil, nil, err
+	}
+	sendable, unsendable, originals := partitionTokens(msgs)
+	report := &UnsendableReport{Recipients: unsendable}
+
+	if len(unsendable) > 0 {
+		counts := make(map[TokenKind]int)
+		for _, recipient := range unsendable {
+			counts[recipient.Kind]++
+		}
+		for kind, count := range counts {
+			c.incCounter(ctx, MetricUnsendableRecipients, float64(count), map[string]string{"kind": kind.String()})
+		}
+		if c.cnf.FallbackProvider != nil {
+			report.FallbackErr = c.cnf.FallbackProvider.SendFallback(ctx, unsendable)
+			if report.FallbackErr != nil {
+				c.log(ctx, LogWarn, "fallback provider failed", map[string]string{
+					"count": strconv.Itoa(len(unsendable)),
+					"error": report.FallbackErr.Error(),
+				})
+			}
+		}
+	}
+
+	if len(sendable) == 0 {
+		return nil, report, nil
+	}
+	responses, err := c.Publish(ctx, sendable)
+	for _, response := range responses {
+		if original, ok := originals[response.MessageItem]; ok {
+			response.MessageItem = original
+		}
+	}
+	return responses, report, err
+}
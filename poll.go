@@ -0,0 +1,80 @@
+package expo
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DefaultReceiptPollConfig polls for receipts over roughly 20 minutes, which
+// covers the delay after which Expo usually has them ready
+func DefaultReceiptPollConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxRetries:      8,
+		InitialInterval: 5 * time.Second,
+		MaxInterval:     5 * time.Minute,
+		Multiplier:      2.0,
+	}
+}
+
+// WaitForReceipts fetches the receipts of ids, then polls again for the ones
+// that are not available yet, backing off as set by policy, until every receipt
+// is found or policy.MaxRetries polls have been made. It returns the receipts
+// found and the IDs still pending, in the order given. If a fetch fails, the
+// receipts found so far are returned along with the error. A nil policy uses
+// DefaultReceiptPollConfig.
+func (c *Client) WaitForReceipts(ctx context.Context, ids []string, policy *RetryConfig) (map[string]*PushReceipt, []string, error) {
+	if policy == nil {
+		policy = DefaultReceiptPollConfig()
+	}
+
+	receipts := make(map[string]*PushReceipt, len(ids))
+	pending := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			pending = append(pending, id)
+		}
+	}
+
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > policy.MaxRetries {
+				break
+			}
+			backoff := policy.ExponentialBackoff(attempt)
+			c.log(ctx, LogDebug, "waiting for pending receipts", map[string]string{
+				"attempt": strconv.Itoa(attempt),
+				"backoff": backoff.String(),
+				"pending": strconv.Itoa(len(pending)),
+			})
+			select {
+			case <-ctx.Done():
+				return receipts, pending, ctx.Err()
+			case <-c.cnf.Clock.After(backoff):
+			}
+		}
+
+		var err error
+		for chunk := range slices.Chunk(pending, c.maxReceiptBatch()) {
+			var found map[string]*PushReceipt
+			if found, err = c.GetPushReceipts(ctx, chunk); err != nil {
+				break
+			}
+			for id, receipt := range found {
+				receipts[id] = receipt
+			}
+		}
+		// Receipts found in earlier chunks are no longer pending, even if a
+		// later chunk failed
+		pending = slices.DeleteFunc(pending, func(id string) bool {
+			return receipts[id] != nil
+		})
+		if err != nil {
+			return receipts, pending, err
+		}
+	}
+	return receipts, pending, nil
+}
@@ -0,0 +1,35 @@
+package expo_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestWaitForReceiptsChunkFails(t *testing.T) {
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		if bytes.Contains(req.Body, []byte("ticket-2")) {
+			writeJSON(w, http.StatusBadRequest, map[string]any{"errors": []map[string]any{{"code": "VALIDATION_ERROR", "message": "bad request"}}})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+			"ticket-0": map[string]any{"status": "ok"},
+		}})
+	})
+	client, _ := newTestClient(s, expo.WithMaxReceiptBatch(2))
+
+	receipts, pending, err := client.WaitForReceipts(context.Background(), []string{"ticket-0", "ticket-1", "ticket-2"}, nil)
+	if err == nil {
+		t.Fatal("WaitForReceipts() succeeded, want the error of the second chunk")
+	}
+	if len(receipts) != 1 || receipts["ticket-0"] == nil {
+		t.Errorf("receipts = %v, want ticket-0", receipts)
+	}
+	if want := []string{"ticket-1", "ticket-2"}; !slices.Equal(pending, want) {
+		t.Errorf("pending = %v, want %v", pending, want)
+	}
+}
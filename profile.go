@@ -0,0 +1,110 @@
+package expo
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Profile is a preset of transport, retry and load settings tuned for a kind of workload
+type Profile int
+
+const (
+	// ProfileDefault applies no settings beyond the client defaults
+	ProfileDefault Profile = iota
+	// ProfileHighThroughput suits services sending large volumes: many pooled
+	// connections, streamed and compressed bodies, and a retry budget that keeps
+	// retries from amplifying load during an incident
+	ProfileHighThroughput
+	// ProfileInteractive suits notifications a user is waiting for, such as
+	// one-time codes: short timeouts, few quick retries and hedged publishing
+	ProfileInteractive
+	// ProfileBackground suits jobs that may take their time: few connections
+	// and patient retries with long backoff
+	ProfileBackground
+)
+
+func (p Profile) String() string {
+	switch p {
+	case ProfileDefault:
+		return "default"
+	case ProfileHighThroughput:
+		return "high-throughput"
+	case ProfileInteractive:
+		return "interactive"
+	case ProfileBackground:
+		return "background"
+	default:
+		return "unknown"
+	}
+}
+
+// Options returns the options making up the profile
+func (p Profile) Options() []Option {
+	switch p {
+	case ProfileHighThroughput:
+		return []Option{
+			WithHttpClient(&http.Client{Timeout: 60 * time.Second, Transport: profileTransport(64)}),
+			WithGzipThreshold(1024),
+			WithStreamingBody(true),
+			WithRetryConfig(&RetryConfig{
+				MaxRetries:      5,
+				InitialInterval: time.Second,
+				MaxInterval:     time.Minute,
+				Multiplier:      2.0,
+			}),
+			WithRetryBudget(&RetryBudget{MaxRatio: 0.1, MinRetries: 10, Window: time.Minute}),
+			WithMaxConcurrentRequests(64),
+		}
+	case ProfileInteractive:
+		return []Option{
+			WithHttpClient(&http.Client{Timeout: 10 * time.Second, Transport: profileTransport(8)}),
+			WithGzipThreshold(8192),
+			WithRetryConfig(&RetryConfig{
+				MaxRetries:      2,
+				InitialInterval: 200 * time.Millisecond,
+				MaxInterval:     2 * time.Second,
+				Multiplier:      2.0,
+			}),
+			WithHedging(time.Second, 1),
+		}
+	case ProfileBackground:
+		return []Option{
+			WithHttpClient(&http.Client{Timeout: 2 * time.Minute, Transport: profileTransport(4)}),
+			WithGzipThreshold(1024),
+			WithRetryConfig(&RetryConfig{
+				MaxRetries:      8,
+				InitialInterval: 5 * time.Second,
+				MaxInterval:     5 * time.Minute,
+				Multiplier:      2.0,
+			}),
+			WithMaxConcurrentRequests(4),
+			WithReceiptSchedule(DefaultReceiptSchedule()),
+		}
+	default:
+		return nil
+	}
+}
+
+// profileTransport returns an HTTP transport keeping up to conns connections to Expo
+func profileTransport(conns int) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          conns,
+		MaxIdleConnsPerHost:   conns,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// NewClientWithProfile creates a client with the settings of profile. Options
+// given in opts are applied afterwards and take precedence.
+func NewClientWithProfile(profile Profile, opts ...Option) *Client {
+	return NewClient(append(profile.Options(), opts...)...)
+}
@@ -1,9 +1,11 @@
 package expo
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type (
@@ -13,6 +15,9 @@ type (
 	Token    string
 )
 
+// InterruptionLevel sets how an iOS notification interrupts the user
+type InterruptionLevel string
+
 const (
 	// NormalPriority is a priority used in PushMessage
 	NormalPriority Priority = "normal"
@@ -21,6 +26,16 @@ const (
 	// DefaultPriority is the standard priority used in PushMessage
 	DefaultPriority Priority = "default"
 
+	// InterruptionLevelPassive adds the notification to the list without lighting the screen or playing a sound
+	InterruptionLevelPassive InterruptionLevel = "passive"
+	// InterruptionLevelActive presents the notification immediately; it is the iOS default
+	InterruptionLevelActive InterruptionLevel = "active"
+	// InterruptionLevelTimeSensitive presents the notification immediately, even during a Focus
+	InterruptionLevelTimeSensitive InterruptionLevel = "time-sensitive"
+	// InterruptionLevelCritical presents the notification and plays a sound even when muted.
+	// It requires the critical alerts entitlement from Apple.
+	InterruptionLevelCritical InterruptionLevel = "critical"
+
 	// ErrorMsgDeviceNotRegistered indicates the token is invalid
 	ErrorMsgDeviceNotRegistered ErrorMsg = "DeviceNotRegistered"
 	// ErrorMsgTooBig indicates the message went over payload size of 4096 bytes
@@ -31,6 +46,19 @@ const (
 	ErrorMsgMismatchSenderID ErrorMsg = "MismatchSenderId"
 	// ErrorMsgInvalidCredentials indicates invalid push credentials
 	ErrorMsgInvalidCredentials ErrorMsg = "InvalidCredentials"
+	// ErrorMsgInvalidProviderToken indicates APNs or FCM rejected the device token
+	// itself; like DeviceNotRegistered, the token should not be used again
+	ErrorMsgInvalidProviderToken ErrorMsg = "InvalidProviderToken"
+	// ErrorMsgProviderError indicates APNs or FCM failed to deliver the message
+	// for a reason of its own, which may not repeat
+	ErrorMsgProviderError ErrorMsg = "ProviderError"
+	// ErrorMsgDeveloperError indicates a problem with the message or the
+	// project's push configuration, described in the message
+	ErrorMsgDeveloperError ErrorMsg = "DeveloperError"
+	// ErrorMsgExpoError indicates an internal error of the Expo push service
+	ErrorMsgExpoError ErrorMsg = "ExpoError"
+	// ErrorMsgInvalidPushToken is reported for recipients removed by lenient validation before sending
+	ErrorMsgInvalidPushToken ErrorMsg = "InvalidPushToken"
 	// ErrMsgMalformedToken is returned if a token does not start with 'ExponentPushToken'
 	ErrMsgMalformedToken ErrorMsg = "token should start with ExponentPushToken"
 
@@ -42,6 +70,24 @@ const (
 	ErrorUnauthorized         ErrorMsg = "UNAUTHORIZED"
 )
 
+// Valid reports whether p is empty or one of the *Priority constants
+func (p Priority) Valid() bool {
+	switch p {
+	case "", NormalPriority, HighPriority, DefaultPriority:
+		return true
+	}
+	return false
+}
+
+// Valid reports whether l is empty or one of the InterruptionLevel* constants
+func (l InterruptionLevel) Valid() bool {
+	switch l {
+	case "", InterruptionLevelPassive, InterruptionLevelActive, InterruptionLevelTimeSensitive, InterruptionLevelCritical:
+		return true
+	}
+	return false
+}
+
 // ParseToken returns a token and may return an error if the input token is invalid
 func ParseToken(token string) (*Token, error) {
 	if !strings.HasPrefix(token, "ExponentPushToken") {
@@ -67,8 +113,9 @@ type Message struct {
 	To []*Token `json:"to"`
 	// The title to display in the notification. On iOS, this is displayed only on Apple Watch.
 	Title string `json:"title,omitempty"`
-	// The message to display in the notification.
-	Body string `json:"body"`
+	// The message to display in the notification. It is omitted when empty, so
+	// data-only (silent) notifications carry no body.
+	Body string `json:"body,omitempty"`
 	// A dict of extra data to pass inside of the push notification. The total notification payload must be at most 4096 bytes.
 	Data Data `json:"data,omitempty"`
 	// A sound to play when the recipient receives this notification.
@@ -81,24 +128,95 @@ type Message struct {
 	// Delivery priority of the message. Use the *Priority constants specified above.
 	Priority Priority `json:"priority,omitempty"`
 	// An integer representing the unread notification count.
-	// This currently only affects iOS. Specify 0 to clear the badge count.
+	// This currently only affects iOS. Since 0 is omitted, set ClearBadge to clear the badge count.
 	Badge int `json:"badge,omitempty"`
+	// ClearBadge sends a badge count of 0, which clears the badge, when Badge is 0
+	ClearBadge bool `json:"-"`
 	// ID of the Notification Channel through which to display this notification on Android devices.
 	ChannelID string `json:"channelId,omitempty"`
 	// iOS only: The subtitle to display in the notification below the title
 	Subtitle string `json:"subtitle,omitempty"`
 	// iOS only: The importance and delivery timing of a notification
-	InterruptionLevel string `json:"interruptionLevel,omitempty"`
+	InterruptionLevel InterruptionLevel `json:"interruptionLevel,omitempty"`
 	// iOS only: When true, notification can be intercepted by the client app
 	MutableContent bool `json:"mutableContent,omitempty"`
 	// iOS only: When true, causes iOS app to start in background to run a background task
 	ContentAvailable bool `json:"_contentAvailable,omitempty"`
+	// iOS only: Ranks the notification among those of the app for the notification
+	// summary, from 0 to 1. Nil leaves it unset, while 0 is sent as a valid score.
+	RelevanceScore *float64 `json:"relevanceScore,omitempty"`
+	// iOS only: Identifier of the app window brought forward when the notification is opened
+	TargetContentID string `json:"targetContentId,omitempty"`
 	// Android only: The notification's icon. Name of an Android drawable resource
 	Icon string `json:"icon,omitempty"`
 	// Rich content support (currently supports setting a notification image)
 	RichContent map[string]string `json:"richContent,omitempty"`
 	// ID of the notification category that this notification is associated with
 	CategoryID string `json:"categoryId,omitempty"`
+	// Notifications with the same collapse ID replace each other instead of stacking
+	// (Android collapse key, APNs apns-collapse-id). Not part of Expo's documented
+	// API: it is sent as is and only takes effect where the push service honors it.
+	CollapseID string `json:"collapseId,omitempty"`
+	// Groups notifications under a common thread (iOS thread-id, Android group).
+	// Like CollapseID, it is sent as is and depends on push service support.
+	ThreadID string `json:"threadId,omitempty"`
+	// Extra holds fields not known to this package yet. They are added to the
+	// JSON object sent to Expo; names that collide with other fields are an error.
+	Extra map[string]any `json:"-"`
+	// Meta holds your own data, such as an internal notification ID. It is never
+	// sent to Expo, but is copied to the tickets and results of the message.
+	Meta map[string]string `json:"-"`
+}
+
+// MarshalExpoJSON returns the JSON object sent to Expo for this message, which
+// is useful to inspect payloads or compare them against Expo's documentation
+func (m *Message) MarshalExpoJSON() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// PayloadSize returns the size in bytes of the JSON object sent to Expo for
+// the message addressed to one recipient, its longest token, which is what
+// Expo's payload limit applies to
+func (m *Message) PayloadSize() (int, error) {
+	single := *m
+	single.To = nil
+	for _, token := range m.To {
+		if token != nil && (single.To == nil || len(*token) > len(*single.To[0])) {
+			single.To = []*Token{token}
+		}
+	}
+	payload, err := single.MarshalExpoJSON()
+	if err != nil {
+		return 0, err
+	}
+	return len(payload), nil
+}
+
+// meta returns the Meta of msg, which may be nil
+func (m *Message) meta() map[string]string {
+	if m == nil {
+		return nil
+	}
+	return m.Meta
+}
+
+// SetTTL sets how long the message may be kept for redelivery, rounded up to
+// whole seconds. A zero or negative d clears the TTL.
+func (m *Message) SetTTL(d time.Duration) {
+	if d <= 0 {
+		m.TTL = 0
+		return
+	}
+	m.TTL = int((d + time.Second - 1) / time.Second)
+}
+
+// SetExpiration sets when the message expires. The zero time clears the expiration.
+func (m *Message) SetExpiration(t time.Time) {
+	if t.IsZero() {
+		m.Expiration = 0
+		return
+	}
+	m.Expiration = t.Unix()
 }
 
 // Response is the HTTP response returned from an Expo publish HTTP request
@@ -117,17 +235,35 @@ type Response struct {
 //	{'status': 'error',
 //	 'message': '"adsf" is not a registered push notification recipient'}
 type MessageResponse struct {
-	MessageItem *Message
-	ID          string `json:"id"`
-	Status      string `json:"status"`
-	Message     string `json:"message"`
-	Details     Data   `json:"details"`
+	MessageItem *Message `json:"-"`
+	// Token is the recipient this ticket was issued for
+	Token   *Token `json:"-"`
+	ID      string `json:"id"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Details Data   `json:"details"`
+	// Err is set when the client itself refused to send the message
+	Err error `json:"-"`
+	// Call describes the HTTP exchange that returned this ticket
+	Call *CallInfo `json:"-"`
+	// Raw is the ticket as received, including fields this package does not know
+	Raw json.RawMessage `json:"-"`
 }
 
 func (r *MessageResponse) IsOk() bool {
 	return r.Status == "ok"
 }
 
+// ErrorCode returns the error code in the ticket details, if any
+func (r *MessageResponse) ErrorCode() ErrorMsg {
+	return ErrorMsg(r.Details["error"])
+}
+
+// IsDeviceNotRegistered checks if the ticket indicates the device is no longer registered
+func (r *MessageResponse) IsDeviceNotRegistered() bool {
+	return r.ErrorCode() == ErrorMsgDeviceNotRegistered
+}
+
 // ServerError is raised when the push token server is not behaving as expected
 // For example, invalid push notification arguments result in a different
 // style of error. Instead of a "data" array containing errors per
@@ -168,6 +304,8 @@ type PushReceipt struct {
 	Status  string `json:"status"`
 	Message string `json:"message,omitempty"`
 	Details Data   `json:"details,omitempty"`
+	// Raw is the receipt as received, including fields this package does not know
+	Raw json.RawMessage `json:"-"`
 }
 
 // IsOk returns true if the receipt status is "ok"
@@ -175,12 +313,14 @@ func (r *PushReceipt) IsOk() bool {
 	return r.Status == "ok"
 }
 
+// ErrorCode returns the error code in the receipt details, if any
+func (r *PushReceipt) ErrorCode() ErrorMsg {
+	return ErrorMsg(r.Details["error"])
+}
+
 // IsDeviceNotRegistered checks if the receipt indicates the device is no longer registered
 func (r *PushReceipt) IsDeviceNotRegistered() bool {
-	if r.Details == nil {
-		return false
-	}
-	return r.Details["error"] == string(ErrorMsgDeviceNotRegistered)
+	return r.ErrorCode() == ErrorMsgDeviceNotRegistered
 }
 
 // PushReceiptRequest represents the request body for fetching push receipts
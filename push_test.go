@@ -0,0 +1,77 @@
+package expo_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestMessageRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *expo.Message
+		json string
+	}{
+		{
+			name: "collapse id",
+			msg:  &expo.Message{To: []*expo.Token{testToken(0)}, Body: "Score 1-0", CollapseID: "match-42"},
+			json: `{"to":["ExponentPushToken[token-0]"],"body":"Score 1-0","collapseId":"match-42"}`,
+		},
+		{
+			name: "thread id",
+			msg:  &expo.Message{To: []*expo.Token{testToken(0)}, Body: "New reply", ThreadID: "conversation-7"},
+			json: `{"to":["ExponentPushToken[token-0]"],"body":"New reply","threadId":"conversation-7"}`,
+		},
+		{
+			name: "grouped update",
+			msg: &expo.Message{
+				To:         []*expo.Token{testToken(0), testToken(1)},
+				Title:      "Delivery",
+				Body:       "Out for delivery",
+				ChannelID:  "orders",
+				CollapseID: "order-9",
+				ThreadID:   "orders",
+				Extra:      map[string]any{"group": "orders"},
+			},
+			json: `{"to":["ExponentPushToken[token-0]","ExponentPushToken[token-1]"],"title":"Delivery","body":"Out for delivery",` +
+				`"channelId":"orders","collapseId":"order-9","threadId":"orders","group":"orders"}`,
+		},
+	}
+	codecs := map[string]expo.Codec{"json": expo.JSONCodec, "gzip": expo.GzipCodec(expo.JSONCodec)}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("Marshal() = %v", err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("Marshal() =\n%s\nwant\n%s", data, tt.json)
+			}
+
+			var decoded expo.Message
+			if err := json.Unmarshal([]byte(tt.json), &decoded); err != nil {
+				t.Fatalf("Unmarshal() = %v", err)
+			}
+			if !reflect.DeepEqual(&decoded, tt.msg) {
+				t.Errorf("Unmarshal() = %+v, want %+v", decoded, *tt.msg)
+			}
+
+			for name, codec := range codecs {
+				encoded, err := codec.Marshal(tt.msg)
+				if err != nil {
+					t.Fatalf("%s Marshal() = %v", name, err)
+				}
+				var got expo.Message
+				if err := codec.Unmarshal(encoded, &got); err != nil {
+					t.Fatalf("%s Unmarshal() = %v", name, err)
+				}
+				if !reflect.DeepEqual(&got, tt.msg) {
+					t.Errorf("%s round trip = %+v, want %+v", name, got, *tt.msg)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// MetricPanics counts panics recovered in background work, by component
+const MetricPanics = "expo_panics_total"
+
+// PanicError is the error a recovered panic is turned into
+type PanicError struct {
+	// Component is the part of the client that panicked, such as "batch sender"
+	Component string
+	Value     any
+	Stack     []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Component, e.Value)
+}
+
+// Unwrap returns the panic value if it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// safely runs fn and turns a panic in it into a PanicError, which is logged
+// with its stack trace and counted. With CrashOnPanic set, the panic is
+// left to crash the process.
+func (c *Client) safely(ctx context.Context, component string, fn func() error) (err error) {
+	if c.cnf.CrashOnPanic {
+		return fn()
+	}
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := &PanicError{Component: component, Value: value, Stack: debug.Stack()}
+			c.log(ctx, LogError, "recovered panic", map[string]string{
+				"component": component,
+				"panic":     fmt.Sprint(value),
+				"stack":     string(panicErr.Stack),
+			})
+			c.incCounter(ctx, MetricPanics, 1, map[string]string{"component": component})
+			err = panicErr
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,51 @@
+package expo
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// tokenPattern matches Expo push tokens in free text, such as API messages
+var tokenPattern = regexp.MustCompile(`(Expo(?:nent)?PushToken)\[([^\]\s"]*)\]`)
+
+// fingerprintLength is the number of trailing characters kept by RedactToken
+const fingerprintLength = 6
+
+// RedactToken replaces every push token in s with a fingerprint that tells
+// tokens apart in logs without revealing them, e.g. "ExponentPushToken[…abc123]"
+func RedactToken(s string) string {
+	return tokenPattern.ReplaceAllStringFunc(s, func(match string) string {
+		parts := tokenPattern.FindStringSubmatch(match)
+		return parts[1] + "[" + fingerprint(parts[2]) + "]"
+	})
+}
+
+// Redacted returns the fingerprint of the token, as RedactToken does for
+// tokens in text. Malformed tokens are fingerprinted as a whole.
+func (t Token) Redacted() string {
+	if tokenPattern.FindString(string(t)) == string(t) {
+		return RedactToken(string(t))
+	}
+	return fingerprint(string(t))
+}
+
+// fingerprint keeps the last characters of id, unless it is too short to keep a part of
+func fingerprint(id string) string {
+	if len(id) < 2*fingerprintLength {
+		return "…"
+	}
+	return "…" + id[len(id)-fingerprintLength:]
+}
+
+// redact removes push tokens and the access token used with ctx from s before
+// it is logged or dumped
+func (c *Client) redact(ctx context.Context, s string) string {
+	s = RedactToken(s)
+	for _, token := range []string{c.cnf.AccessToken, c.accessToken(ctx)} {
+		if token != "" {
+			s = strings.ReplaceAll(s, token, redacted)
+		}
+	}
+	return s
+}
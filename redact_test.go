@@ -0,0 +1,106 @@
+package expo_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// recordingLogger keeps every log entry as text
+type recordingLogger struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (l *recordingLogger) Log(level expo.LogLevel, msg string, fields map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, fmt.Sprintf("%s %s %v", level, msg, fields))
+}
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.entries, "\n")
+}
+
+// TestSecretsNotLeaked sends through a client that logs and dumps everything,
+// with Expo echoing the push token in its errors, and checks that neither the
+// access token nor the push token appear in the dump, the logs or the errors
+func TestSecretsNotLeaked(t *testing.T) {
+	const accessToken = "expo-access-token-0123456789"
+	const tokenID = "abcdefghijklmnopqrstuv"
+	token := expo.MustParseToken("ExponentPushToken[" + tokenID + "]")
+	notRegistered := fmt.Sprintf("%q is not a registered push notification recipient", string(*token))
+
+	failed := false
+	s := newAPIServer(t, func(w http.ResponseWriter, req recordedRequest) {
+		switch {
+		case !failed:
+			failed = true
+			// An error echoing the request's credentials
+			message := notRegistered + ", sent with " + req.Header.Get("Authorization")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"errors": []map[string]string{{"message": message}}})
+		case strings.HasSuffix(req.Path, "/push/send"):
+			writeJSON(w, http.StatusOK, map[string]any{"data": []map[string]any{
+				{"status": "ok", "id": "ticket-0"},
+				{"status": "error", "message": notRegistered, "details": map[string]string{"error": "DeviceNotRegistered"}},
+			}})
+		default:
+			writeJSON(w, http.StatusOK, map[string]any{"data": map[string]any{
+				"ticket-0": map[string]any{"status": "error", "message": notRegistered, "details": map[string]string{"error": "DeviceNotRegistered"}},
+			}})
+		}
+	})
+	var dump bytes.Buffer
+	logger := &recordingLogger{}
+	client, _ := newTestClient(s, expo.WithAccessToken(accessToken), expo.WithDebugDump(&dump), expo.WithLogger(logger))
+	ctx := context.Background()
+
+	var errs []string
+	results, err := client.SendPushNotificationsWithReceipts(ctx, []*expo.Message{{To: []*expo.Token{token, token}, Body: "hello"}}, 0)
+	if err != nil {
+		t.Fatalf("SendPushNotificationsWithReceipts() = %v", err)
+	}
+	for _, result := range results {
+		if result.Error == nil {
+			t.Errorf("result %s has no error", result.TicketID)
+			continue
+		}
+		errs = append(errs, result.Error.Error())
+	}
+
+	// Validation errors name the invalid token too
+	invalid := expo.Token("ExpoPushTok[" + tokenID + "]")
+	strict, _ := newTestClient(s, expo.WithValidation(expo.ValidationStrict), expo.WithLogger(logger))
+	if _, err := strict.PublishSingle(ctx, &expo.Message{To: []*expo.Token{&invalid}, Body: "hello"}); err == nil {
+		t.Error("PublishSingle() of an invalid token succeeded")
+	} else {
+		errs = append(errs, err.Error())
+	}
+
+	outputs := map[string]string{
+		"debug dump": dump.String(),
+		"logs":       logger.String(),
+		"errors":     strings.Join(errs, "\n"),
+	}
+	for name, output := range outputs {
+		if output == "" {
+			t.Errorf("no %s recorded", name)
+		}
+		for _, secret := range []string{accessToken, tokenID} {
+			if strings.Contains(output, secret) {
+				t.Errorf("%s leaks %q:\n%s", name, secret, output)
+			}
+		}
+	}
+	if !strings.Contains(outputs["errors"], "ExponentPushToken[…qrstuv]") {
+		t.Errorf("errors do not fingerprint the token:\n%s", outputs["errors"])
+	}
+}
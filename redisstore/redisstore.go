@@ -0,0 +1,124 @@
+// Package redisstore provides Redis-backed TicketStore and DedupeStore
+// implementations, so that several sender instances can share receipt
+// tracking and idempotency state. It is a separate module to keep the Redis
+// dependency out of the main package.
+package redisstore
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TicketStore keeps tickets in a Redis hash keyed by ticket ID
+type TicketStore struct {
+	rdb   redis.UniversalClient
+	key   string
+	codec expo.Codec
+}
+
+// NewTicketStore creates a TicketStore storing tickets under prefix as JSON
+func NewTicketStore(rdb redis.UniversalClient, prefix string) *TicketStore {
+	return &TicketStore{rdb: rdb, key: prefix + ":tickets", codec: expo.JSONCodec}
+}
+
+// WithCodec sets the codec tickets are stored with and returns the store.
+// Tickets stored with another codec can no longer be read.
+func (s *TicketStore) WithCodec(codec expo.Codec) *TicketStore {
+	s.codec = codec
+	return s
+}
+
+var _ expo.TicketStore = (*TicketStore)(nil)
+
+func (s *TicketStore) Add(ctx context.Context, tickets []*expo.Ticket) error {
+	if len(tickets) == 0 {
+		return nil
+	}
+	values := make([]any, 0, 2*len(tickets))
+	for _, ticket := range tickets {
+		data, err := s.codec.Marshal(ticket)
+		if err != nil {
+			return err
+		}
+		values = append(values, ticket.ID, data)
+	}
+	return s.rdb.HSet(ctx, s.key, values...).Err()
+}
+
+func (s *TicketStore) Pending(ctx context.Context) ([]*expo.Ticket, error) {
+	entries, err := s.rdb.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	tickets := make([]*expo.Ticket, 0, len(entries))
+	for _, data := range entries {
+		var ticket expo.Ticket
+		if err := s.codec.Unmarshal([]byte(data), &ticket); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, &ticket)
+	}
+	slices.SortFunc(tickets, func(a, b *expo.Ticket) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return tickets, nil
+}
+
+func (s *TicketStore) Remove(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.rdb.HDel(ctx, s.key, ids...).Err()
+}
+
+// DedupeStore records dedupe keys as Redis keys expiring after their TTL
+type DedupeStore struct {
+	rdb    redis.UniversalClient
+	prefix string
+}
+
+// NewDedupeStore creates a DedupeStore storing keys under prefix
+func NewDedupeStore(rdb redis.UniversalClient, prefix string) *DedupeStore {
+	return &DedupeStore{rdb: rdb, prefix: prefix + ":dedupe:"}
+}
+
+var _ expo.DedupeStore = (*DedupeStore)(nil)
+
+func (s *DedupeStore) Reserve(ctx context.Context, keys []string, ttl time.Duration) ([]string, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.SetNX(ctx, s.prefix+key, 1, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var reserved []string
+	for i, cmd := range cmds {
+		if cmd.Val() {
+			reserved = append(reserved, keys[i])
+		}
+	}
+	return reserved, nil
+}
+
+func (s *DedupeStore) Release(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = s.prefix + key
+	}
+	return s.rdb.Del(ctx, prefixed...).Err()
+}
@@ -0,0 +1,32 @@
+package redisstore_test
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+	"dezeto/expo-push-notification/redisstore"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestDedupeStore runs against the Redis server at REDISSTORE_TEST_ADDR, and
+// is skipped if it is not set
+func TestDedupeStore(t *testing.T) {
+	addr := os.Getenv("REDISSTORE_TEST_ADDR")
+	if addr == "" {
+		t.Skip("REDISSTORE_TEST_ADDR not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+
+	run := time.Now().UnixNano()
+	n := 0
+	expotest.TestDedupeStore(t, func(t *testing.T) expo.DedupeStore {
+		n++
+		return redisstore.NewDedupeStore(rdb, fmt.Sprintf("expotest:%d:%d", run, n))
+	})
+}
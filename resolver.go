@@ -0,0 +1,77 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+)
+
+// TokenResolver looks up the push tokens registered for each user
+type TokenResolver interface {
+	Resolve(ctx context.Context, userIDs []string) (map[string][]*Token, error)
+}
+
+// TokenResolverFunc adapts an ordinary function to the TokenResolver interface
+type TokenResolverFunc func(ctx context.Context, userIDs []string) (map[string][]*Token, error)
+
+// Resolve calls f(ctx, userIDs)
+func (f TokenResolverFunc) Resolve(ctx context.Context, userIDs []string) (map[string][]*Token, error) {
+	return f(ctx, userIDs)
+}
+
+// LocationResolver is implemented by TokenResolvers that also know the time
+// zone of each user. The messages of PublishToUsers then carry it in
+// MetaTimeZone, so that a DeliveryWindow defers each one to its user's hours.
+type LocationResolver interface {
+	ResolveLocations(ctx context.Context, userIDs []string) (map[string]*time.Location, error)
+}
+
+// PublishToUsers resolves the tokens of userIDs with the configured
+// TokenResolver and sends each user a copy of msg addressed to all of their
+// tokens, chunking the messages with a BatchSender. Users without tokens are skipped.
+func (c *Client) PublishToUsers(ctx context.Context, userIDs []string, msg *Message) (*BatchReport, error) {
+	msgs, err := c.userMessages(ctx, userIDs, msg)
+	if err != nil {
+		return nil, err
+	}
+	return NewBatchSender(c, nil).Send(ctx, msgs)
+}
+
+// userMessages returns a copy of msg for each user of userIDs with tokens,
+// addressed to their tokens and, if the resolver knows it, carrying their time zone
+func (c *Client) userMessages(ctx context.Context, userIDs []string, msg *Message) ([]*Message, error) {
+	if c.cnf.TokenResolver == nil {
+		return nil, errors.New("no token resolver configured")
+	}
+
+	tokens, err := c.cnf.TokenResolver.Resolve(ctx, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve tokens: %w", err)
+	}
+	var locations map[string]*time.Location
+	if resolver, ok := c.cnf.TokenResolver.(LocationResolver); ok {
+		if locations, err = resolver.ResolveLocations(ctx, userIDs); err != nil {
+			return nil, fmt.Errorf("failed to resolve time zones: %w", err)
+		}
+	}
+
+	var msgs []*Message
+	for _, userID := range userIDs {
+		if len(tokens[userID]) == 0 {
+			continue
+		}
+		userMsg := *msg
+		userMsg.To = tokens[userID]
+		if loc := locations[userID]; loc != nil {
+			userMsg.Meta = maps.Clone(msg.Meta)
+			if userMsg.Meta == nil {
+				userMsg.Meta = make(map[string]string)
+			}
+			userMsg.Meta[MetaTimeZone] = loc.String()
+		}
+		msgs = append(msgs, &userMsg)
+	}
+	return msgs, nil
+}
@@ -2,8 +2,11 @@ package expo
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -13,6 +16,9 @@ type RetryConfig struct {
 	InitialInterval time.Duration
 	MaxInterval     time.Duration
 	Multiplier      float64
+	// ShouldRetry decides whether a failed attempt is retried; by default only
+	// errors classified as ErrorClassTemporary are
+	ShouldRetry func(err error) bool
 }
 
 // DefaultRetryConfig provides sensible defaults for retry logic
@@ -25,6 +31,36 @@ func DefaultRetryConfig() *RetryConfig {
 	}
 }
 
+// Validate reports settings that would make retries misbehave. Backoff
+// settings are only checked when MaxRetries allows retrying at all.
+func (c *RetryConfig) Validate() error {
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("MaxRetries must not be negative, got %d", c.MaxRetries)
+	}
+	if c.MaxRetries == 0 {
+		return nil
+	}
+	var errs []error
+	if c.InitialInterval <= 0 {
+		errs = append(errs, fmt.Errorf("InitialInterval must be positive, got %s", c.InitialInterval))
+	}
+	if c.MaxInterval < c.InitialInterval {
+		errs = append(errs, fmt.Errorf("MaxInterval (%s) must not be less than InitialInterval (%s)", c.MaxInterval, c.InitialInterval))
+	}
+	if c.Multiplier < 1 {
+		errs = append(errs, fmt.Errorf("Multiplier must be at least 1, got %g", c.Multiplier))
+	}
+	return errors.Join(errs...)
+}
+
+// shouldRetry returns true if an attempt that failed with err may be retried
+func (c *RetryConfig) shouldRetry(err error) bool {
+	if c.ShouldRetry != nil {
+		return c.ShouldRetry(err)
+	}
+	return ClassifyError(err) == ErrorClassTemporary
+}
+
 // IsRetryableError checks if an HTTP response indicates a retryable error
 func IsRetryableError(statusCode int) bool {
 	switch statusCode {
@@ -55,6 +91,23 @@ func (c *RetryConfig) ExponentialBackoff(attempt int) time.Duration {
 	return backoff
 }
 
+// parseRetryAfter interprets a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
 // WithRetry executes a function with exponential backoff retry logic
 func (c *Client) WithRetry(ctx context.Context, retryConfig *RetryConfig, fn func() (*http.Response, error)) (*http.Response, error) {
 	if retryConfig == nil {
@@ -63,29 +116,59 @@ func (c *Client) WithRetry(ctx context.Context, retryConfig *RetryConfig, fn fun
 
 	var lastErr error
 	var resp *http.Response
+	var retryAfter time.Duration
+	var history []AttemptError
+	start := c.cnf.Clock.Now()
 
 	for attempt := 0; attempt <= retryConfig.MaxRetries; attempt++ {
 		if attempt > 0 {
 			backoff := retryConfig.ExponentialBackoff(attempt)
+			if retryAfter > backoff {
+				backoff = min(retryAfter, retryConfig.MaxInterval)
+			}
+			if !c.cnf.RetryBudget.tryRetry(c.cnf.Clock.Now()) {
+				c.log(ctx, LogWarn, "retry budget exhausted", map[string]string{"error": lastErr.Error()})
+				return nil, &RetryBudgetExhaustedError{LastErr: lastErr}
+			}
+			c.incCounter(ctx, MetricRetries, 1, nil)
+			c.log(ctx, LogInfo, "retrying request", map[string]string{
+				"attempt": strconv.Itoa(attempt),
+				"backoff": backoff.String(),
+				"error":   lastErr.Error(),
+			})
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
-			case <-time.After(backoff):
+			case <-c.cnf.Clock.After(backoff):
 				// Continue with retry
 			}
 		}
 
+		c.cnf.RetryBudget.recordRequest(c.cnf.Clock.Now())
 		resp, lastErr = fn()
 		if lastErr != nil {
-			continue
+			if retryConfig.shouldRetry(lastErr) {
+				history = append(history, AttemptError{Err: lastErr})
+				continue
+			}
+			if class := ClassifyError(lastErr); class != ErrorClassCanceled {
+				c.log(ctx, LogWarn, "request failed permanently", map[string]string{
+					"class": class.String(),
+					"error": lastErr.Error(),
+				})
+				return nil, &NonRetryableError{Class: class, Err: lastErr}
+			}
+			return nil, lastErr
 		}
 
 		if resp != nil && IsRetryableError(resp.StatusCode) {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), c.cnf.Clock.Now())
 			resp.Body.Close()
 			lastErr = &ServerError{
 				Message:  "retryable error",
 				Response: resp,
 			}
+			history = append(history, AttemptError{StatusCode: resp.StatusCode, Err: lastErr})
 			continue
 		}
 
@@ -93,5 +176,42 @@ func (c *Client) WithRetry(ctx context.Context, retryConfig *RetryConfig, fn fun
 		return resp, lastErr
 	}
 
-	return nil, lastErr
+	return nil, &RetryExhaustedError{
+		Attempts: history,
+		Elapsed:  c.cnf.Clock.Now().Sub(start),
+		LastErr:  lastErr,
+	}
+}
+
+// AttemptError records the failure of one attempt made by WithRetry
+type AttemptError struct {
+	// StatusCode is the HTTP status of the response, or zero if none was received
+	StatusCode int
+	Err        error
+}
+
+// RetryExhaustedError is returned by WithRetry when every allowed attempt failed
+type RetryExhaustedError struct {
+	// Attempts lists the failure of every attempt, in order
+	Attempts []AttemptError
+	// Elapsed is the time from the first attempt until giving up, including backoff
+	Elapsed time.Duration
+	LastErr error
+}
+
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("giving up after %d attempts in %s: %v", len(e.Attempts), e.Elapsed.Round(time.Millisecond), e.LastErr)
+}
+
+func (e *RetryExhaustedError) Unwrap() error {
+	return e.LastErr
+}
+
+// StatusCodes returns the HTTP status of every attempt, zero where none was received
+func (e *RetryExhaustedError) StatusCodes() []int {
+	codes := make([]int, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		codes[i] = attempt.StatusCode
+	}
+	return codes
 }
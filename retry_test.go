@@ -0,0 +1,40 @@
+package expo_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// BenchmarkWithRetry measures the retry loop itself: attempts answer with 503
+// until the last one succeeds, and the client's clock never waits
+func BenchmarkWithRetry(b *testing.B) {
+	for _, failures := range []int{0, 3} {
+		b.Run(fmt.Sprintf("failures=%d", failures), func(b *testing.B) {
+			client := expo.NewClient(expo.WithClock(newInstantClock()))
+			cnf := expo.DefaultRetryConfig()
+			ctx := context.Background()
+			b.ReportAllocs()
+			for range b.N {
+				attempt := 0
+				resp, err := client.WithRetry(ctx, cnf, func() (*http.Response, error) {
+					status := http.StatusOK
+					if attempt < failures {
+						status = http.StatusServiceUnavailable
+					}
+					attempt++
+					return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+		})
+	}
+}
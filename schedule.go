@@ -0,0 +1,84 @@
+package expo
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReceiptUnavailable is reported for tickets whose receipt was still not
+// available when the ReceiptSchedule gave up on it
+var ErrReceiptUnavailable = errors.New("push receipt not available")
+
+// ReceiptSchedule decides when push receipts are checked. Expo recommends a
+// first check about 15 minutes after sending; receipts that are not ready yet
+// can be checked again until Expo deletes them after ReceiptRetention.
+type ReceiptSchedule struct {
+	// FirstCheck is how long after sending receipts are first fetched
+	FirstCheck time.Duration
+	// Interval is the delay between later checks of receipts that were not
+	// available yet; zero checks only once
+	Interval time.Duration
+	// GiveUpAfter is how long after sending checks stop; defaults to ReceiptRetention
+	GiveUpAfter time.Duration
+}
+
+// DefaultReceiptSchedule follows Expo's guidance: check after 15 minutes, then
+// every 15 minutes until the receipts expire
+func DefaultReceiptSchedule() *ReceiptSchedule {
+	return &ReceiptSchedule{
+		FirstCheck:  15 * time.Minute,
+		Interval:    15 * time.Minute,
+		GiveUpAfter: ReceiptRetention,
+	}
+}
+
+// Due returns true if a ticket issued at createdAt may be checked at now
+func (s *ReceiptSchedule) Due(createdAt, now time.Time) bool {
+	return now.Sub(createdAt) >= s.FirstCheck
+}
+
+// GaveUp returns true if a ticket issued at createdAt is no longer checked at now
+func (s *ReceiptSchedule) GaveUp(createdAt, now time.Time) bool {
+	return now.Sub(createdAt) > s.giveUpAfter()
+}
+
+// checkAt returns when check n, counting from zero, of a ticket issued at
+// createdAt is due, or false if the schedule has given up by then
+func (s *ReceiptSchedule) checkAt(createdAt time.Time, n int) (time.Time, bool) {
+	if n == 0 {
+		return createdAt.Add(s.FirstCheck), true
+	}
+	if s.Interval <= 0 {
+		return time.Time{}, false
+	}
+	elapsed := s.FirstCheck + time.Duration(n)*s.Interval
+	return createdAt.Add(elapsed), elapsed <= s.giveUpAfter()
+}
+
+func (s *ReceiptSchedule) giveUpAfter() time.Duration {
+	if s.GiveUpAfter <= 0 {
+		return ReceiptRetention
+	}
+	return s.GiveUpAfter
+}
+
+// workflowSchedule returns the schedule of a workflow called with receiptDelay.
+// A non-zero delay keeps the single check made before schedules existed.
+func (c *Client) workflowSchedule(receiptDelay time.Duration) *ReceiptSchedule {
+	if receiptDelay == 0 && c.cnf.ReceiptSchedule != nil {
+		return c.cnf.ReceiptSchedule
+	}
+	if receiptDelay == 0 {
+		receiptDelay = 15 * time.Minute
+	}
+	return &ReceiptSchedule{FirstCheck: receiptDelay}
+}
+
+// trackerSchedule returns the schedule used by ReceiptTracker.Check, which by
+// default checks every ticket whenever it is called
+func (c *Client) trackerSchedule() *ReceiptSchedule {
+	if c.cnf.ReceiptSchedule != nil {
+		return c.cnf.ReceiptSchedule
+	}
+	return &ReceiptSchedule{}
+}
@@ -0,0 +1,174 @@
+package expo
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ShardStrategy decides which credential of a BatchSender publishes a chunk
+type ShardStrategy int
+
+const (
+	// ShardRoundRobin spreads chunks evenly across all credentials
+	ShardRoundRobin ShardStrategy = iota
+	// ShardByProject publishes each chunk with the credential listing the
+	// project of its messages, keeping projects apart
+	ShardByProject
+)
+
+func (s ShardStrategy) String() string {
+	switch s {
+	case ShardRoundRobin:
+		return "round-robin"
+	case ShardByProject:
+		return "by-project"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	// MetricCredentialChunks counts chunks published by a sharded BatchSender, labeled by credential and status
+	MetricCredentialChunks = "expo_credential_chunks_total"
+	// MetricCredentialWait measures how long chunks waited for the rate limit of their credential, labeled by credential
+	MetricCredentialWait = "expo_credential_wait_seconds"
+)
+
+// Credential is an access token a BatchSender may publish chunks with
+type Credential struct {
+	// Name identifies the credential in chunk reports and metrics; defaults to its index
+	Name        string
+	AccessToken string
+	// Projects lists the projects, as returned by BatchConfig.ProjectOf, that
+	// ShardByProject publishes with this credential
+	Projects []string
+	// RequestsPerSecond limits the publish requests made with the credential; zero means no limit
+	RequestsPerSecond float64
+}
+
+// shard is a credential in use by a BatchSender
+type shard struct {
+	name    string
+	token   string
+	limiter *rateLimiter
+}
+
+// shards routes the chunks of a BatchSender to its credentials
+type shards struct {
+	strategy  ShardStrategy
+	projectOf func(msg *Message) string
+	list      []*shard
+	byName    map[string]*shard
+	byProject map[string]*shard
+	next      atomic.Uint64
+}
+
+func newShards(cnf *BatchConfig, clock Clock) *shards {
+	if len(cnf.Credentials) == 0 {
+		return nil
+	}
+	s := &shards{
+		strategy:  cnf.Sharding,
+		projectOf: cnf.ProjectOf,
+		byName:    make(map[string]*shard),
+		byProject: make(map[string]*shard),
+	}
+	for i, cred := range cnf.Credentials {
+		sh := &shard{name: cred.Name, token: cred.AccessToken}
+		if sh.name == "" {
+			sh.name = strconv.Itoa(i)
+		}
+		if cred.RequestsPerSecond > 0 {
+			sh.limiter = &rateLimiter{clock: clock, interval: time.Duration(float64(time.Second) / cred.RequestsPerSecond)}
+		}
+		s.list = append(s.list, sh)
+		s.byName[sh.name] = sh
+		for _, project := range cred.Projects {
+			s.byProject[project] = sh
+		}
+	}
+	return s
+}
+
+// project returns the project of msg for ShardByProject
+func (s *shards) project(msg *Message) string {
+	if s.projectOf == nil || msg == nil {
+		return ""
+	}
+	return s.projectOf(msg)
+}
+
+// pick returns the credential publishing chunk, keeping the one it was
+// assigned before so that a resumed chunk is sent the same way
+func (s *shards) pick(chunk *ChunkReport) (*shard, error) {
+	if sh, ok := s.byName[chunk.Credential]; ok {
+		return sh, nil
+	}
+	if s.strategy == ShardByProject {
+		var project string
+		if len(chunk.Messages) > 0 {
+			project = s.project(chunk.Messages[0])
+		}
+		sh, ok := s.byProject[project]
+		if !ok {
+			return nil, fmt.Errorf("no credential for project %q", project)
+		}
+		return sh, nil
+	}
+	return s.list[(s.next.Add(1)-1)%uint64(len(s.list))], nil
+}
+
+// groupByProject orders msgs so that messages of the same project are
+// adjacent, keeping the order of projects and of messages within each
+func (s *shards) groupByProject(msgs []*Message) [][]*Message {
+	var groups [][]*Message
+	index := make(map[string]int)
+	for _, msg := range msgs {
+		project := s.project(msg)
+		i, ok := index[project]
+		if !ok {
+			i = len(groups)
+			index[project] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], msg)
+	}
+	return groups
+}
+
+// rateLimiter spaces requests evenly at a fixed interval
+type rateLimiter struct {
+	clock    Clock
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next request may be made or ctx is done
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.clock.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-l.clock.After(delay):
+		}
+	}
+	return nil
+}
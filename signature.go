@@ -0,0 +1,169 @@
+package expo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSignatureTolerance is how far the timestamp of a signed webhook may
+// be from the receiver's clock before it is rejected
+const DefaultSignatureTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature is matched by errors of webhooks whose signature is missing, malformed or wrong
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleSignature is matched by errors of webhooks signed too long ago, or in the future
+	ErrStaleSignature = errors.New("webhook signature timestamp outside tolerance")
+	// ErrReplayedSignature is matched by errors of webhooks already accepted once
+	ErrReplayedSignature = errors.New("webhook already received")
+)
+
+// WebhookSigner signs webhook bodies, such as push delivery events, for a
+// WebhookVerifier. The signature header has the form
+// "t=<unix seconds>,sha256=<hex>", where the HMAC-SHA256 covers the timestamp,
+// a dot and the body. During key rotation, give both the new and the old
+// secret: the body is signed with each, so receivers accept it with either.
+type WebhookSigner struct {
+	secrets [][]byte
+	clock   Clock
+}
+
+// NewWebhookSigner creates a WebhookSigner signing with every secret
+func NewWebhookSigner(secrets ...[]byte) *WebhookSigner {
+	return &WebhookSigner{secrets: secrets, clock: SystemClock}
+}
+
+// WithClock sets the clock used for timestamps and returns the signer
+func (s *WebhookSigner) WithClock(clock Clock) *WebhookSigner {
+	s.clock = clock
+	return s
+}
+
+// Sign returns the signature header value for body
+func (s *WebhookSigner) Sign(body []byte) string {
+	timestamp := strconv.FormatInt(s.clock.Now().Unix(), 10)
+	parts := []string{"t=" + timestamp}
+	for _, secret := range s.secrets {
+		parts = append(parts, "sha256="+hex.EncodeToString(signatureMAC(secret, timestamp, body)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// SignRequest sets the ReceiptSignatureHeader of req to the signature of body
+func (s *WebhookSigner) SignRequest(req *http.Request, body []byte) {
+	req.Header.Set(ReceiptSignatureHeader, s.Sign(body))
+}
+
+// WebhookVerifier checks the signatures made by a WebhookSigner. It accepts a
+// signature made with any of its secrets, rejects timestamps further than the
+// tolerance from its clock, and remembers accepted signatures for as long as
+// their timestamp is within tolerance, so that a captured request cannot be
+// replayed, even with its signature header rewritten.
+type WebhookVerifier struct {
+	secrets   [][]byte
+	tolerance time.Duration
+	clock     Clock
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewWebhookVerifier creates a WebhookVerifier accepting signatures made with
+// any of secrets. A tolerance of zero means DefaultSignatureTolerance.
+func NewWebhookVerifier(tolerance time.Duration, secrets ...[]byte) *WebhookVerifier {
+	if tolerance <= 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	return &WebhookVerifier{secrets: secrets, tolerance: tolerance, clock: SystemClock, seen: make(map[string]time.Time)}
+}
+
+// WithClock sets the clock timestamps are checked against and returns the verifier
+func (v *WebhookVerifier) WithClock(clock Clock) *WebhookVerifier {
+	v.clock = clock
+	return v
+}
+
+// Verify checks the signature header value of body. Errors match
+// ErrInvalidSignature, ErrStaleSignature or ErrReplayedSignature.
+func (v *WebhookVerifier) Verify(body []byte, header string) error {
+	var timestamp string
+	var sums [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "sha256":
+			if sum, err := hex.DecodeString(value); err == nil {
+				sums = append(sums, sum)
+			}
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(sums) == 0 {
+		return fmt.Errorf("%w: missing timestamp or signature", ErrInvalidSignature)
+	}
+
+	if !v.matches(timestamp, body, sums) {
+		return ErrInvalidSignature
+	}
+	now := v.clock.Now()
+	signedAt := time.Unix(seconds, 0)
+	if signedAt.Before(now.Add(-v.tolerance)) || signedAt.After(now.Add(v.tolerance)) {
+		return fmt.Errorf("%w: signed at %s", ErrStaleSignature, signedAt.UTC().Format(time.RFC3339))
+	}
+	return v.remember(replayKey(timestamp, body), signedAt.Add(v.tolerance), now)
+}
+
+// replayKey identifies a signed webhook by its timestamp and body, so that a
+// replay is recognized however its signature header is rewritten
+func replayKey(timestamp string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return timestamp + "." + hex.EncodeToString(sum[:])
+}
+
+// matches reports whether any of sums is the signature of timestamp and body with one of the secrets
+func (v *WebhookVerifier) matches(timestamp string, body []byte, sums [][]byte) bool {
+	for _, secret := range v.secrets {
+		expected := signatureMAC(secret, timestamp, body)
+		for _, sum := range sums {
+			if hmac.Equal(sum, expected) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// remember records an accepted webhook until expiry, rejecting one seen before
+func (v *WebhookVerifier) remember(key string, expiry, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for seen, until := range v.seen {
+		if until.Before(now) {
+			delete(v.seen, seen)
+		}
+	}
+	if _, ok := v.seen[key]; ok {
+		return ErrReplayedSignature
+	}
+	v.seen[key] = expiry
+	return nil
+}
+
+// signatureMAC returns the HMAC-SHA256 of timestamp, a dot and body
+func signatureMAC(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
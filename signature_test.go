@@ -0,0 +1,97 @@
+package expo_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+	"dezeto/expo-push-notification/expotest"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+	oldSecret, newSecret := []byte("old-secret"), []byte("new-secret")
+	body := []byte(`{"id":"ticket-1","status":"ok"}`)
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		signer  [][]byte
+		signAt  time.Time
+		body    []byte
+		header  func(string) string
+		wantErr error
+	}{
+		{name: "valid", signer: [][]byte{newSecret}, signAt: now},
+		{name: "old secret during rotation", signer: [][]byte{oldSecret}, signAt: now},
+		{name: "both secrets", signer: [][]byte{newSecret, oldSecret}, signAt: now},
+		{name: "unknown secret", signer: [][]byte{[]byte("other")}, signAt: now, wantErr: expo.ErrInvalidSignature},
+		{name: "tampered body", signer: [][]byte{newSecret}, signAt: now, body: []byte(`{"status":"error"}`), wantErr: expo.ErrInvalidSignature},
+		{name: "missing timestamp", signer: [][]byte{newSecret}, signAt: now, header: func(h string) string {
+			_, sums, _ := strings.Cut(h, ",")
+			return sums
+		}, wantErr: expo.ErrInvalidSignature},
+		{name: "empty header", signer: [][]byte{newSecret}, signAt: now, header: func(string) string { return "" }, wantErr: expo.ErrInvalidSignature},
+		{name: "stale", signer: [][]byte{newSecret}, signAt: now.Add(-10 * time.Minute), wantErr: expo.ErrStaleSignature},
+		{name: "future", signer: [][]byte{newSecret}, signAt: now.Add(10 * time.Minute), wantErr: expo.ErrStaleSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := expo.NewWebhookSigner(tt.signer...).WithClock(expotest.NewFakeClock(tt.signAt)).Sign(body)
+			if tt.header != nil {
+				header = tt.header(header)
+			}
+			received := body
+			if tt.body != nil {
+				received = tt.body
+			}
+			verifier := expo.NewWebhookVerifier(0, newSecret, oldSecret).WithClock(expotest.NewFakeClock(now))
+			if err := verifier.Verify(received, header); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifierRejectsReplays(t *testing.T) {
+	secret, oldSecret := []byte("secret"), []byte("old-secret")
+	body := []byte(`{"id":"ticket-1","status":"ok"}`)
+	clock := expotest.NewFakeClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	header := expo.NewWebhookSigner(secret, oldSecret).WithClock(clock).Sign(body)
+	timestamp, sums, _ := strings.Cut(header, ",")
+	firstSum, secondSum, _ := strings.Cut(sums, ",")
+
+	verifier := expo.NewWebhookVerifier(time.Minute, secret, oldSecret).WithClock(clock)
+	if err := verifier.Verify(body, header); err != nil {
+		t.Fatalf("first Verify() = %v", err)
+	}
+
+	// A captured request replayed with a cosmetically changed header is still a replay
+	replays := map[string]string{
+		"unchanged":         header,
+		"leading space":     " " + header,
+		"spaces after sums": strings.ReplaceAll(header, ",", ", "),
+		"unknown part":      header + ",x=y",
+		"duplicated sum":    header + "," + firstSum,
+		"reordered":         strings.Join([]string{secondSum, firstSum, timestamp}, ","),
+		"first sum only":    timestamp + "," + firstSum,
+		"second sum only":   timestamp + "," + secondSum,
+	}
+	for name, replay := range replays {
+		if err := verifier.Verify(body, replay); !errors.Is(err, expo.ErrReplayedSignature) {
+			t.Errorf("%s: Verify() = %v, want %v", name, err, expo.ErrReplayedSignature)
+		}
+	}
+
+	// Once the timestamp is out of tolerance the signature is stale rather than remembered
+	clock.Advance(2 * time.Minute)
+	if err := verifier.Verify(body, header); !errors.Is(err, expo.ErrStaleSignature) {
+		t.Errorf("Verify() after tolerance = %v, want %v", err, expo.ErrStaleSignature)
+	}
+
+	// A new webhook with the same body signed later is not a replay
+	if err := verifier.Verify(body, expo.NewWebhookSigner(secret).WithClock(clock).Sign(body)); err != nil {
+		t.Errorf("Verify() of a new signature = %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+// Package sqlsink provides a ResultSink that persists push results with
+// database/sql, using a driver registered by the application.
+//
+// Results are stored in a table with the following schema, which Migrate
+// creates if it does not exist:
+//
+//	CREATE TABLE IF NOT EXISTS push_results (
+//		ticket_id       VARCHAR(64),
+//		token           VARCHAR(255),
+//		classification  VARCHAR(32) NOT NULL,
+//		error_code      VARCHAR(64),
+//		error           TEXT,
+//		ticket_status   VARCHAR(16),
+//		ticket_message  TEXT,
+//		receipt_status  VARCHAR(16),
+//		receipt_message TEXT,
+//		attempts        INTEGER NOT NULL,
+//		created_at      TIMESTAMP,
+//		recorded_at     TIMESTAMP NOT NULL,
+//		meta            TEXT
+//	)
+//
+// meta holds the Meta of the message as a JSON object, or NULL if it has none.
+// The column is TEXT to be portable; it may be declared as JSON or JSONB
+// instead where the database supports it. Tables created before the column
+// was added need it added, e.g. with ALTER TABLE push_results ADD COLUMN meta TEXT.
+package sqlsink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	expo "dezeto/expo-push-notification"
+)
+
+// Placeholder returns the bind parameter for the n-th argument, starting at 1
+type Placeholder func(n int) string
+
+// Question produces "?" placeholders, as used by MySQL and SQLite
+func Question(n int) string {
+	return "?"
+}
+
+// Dollar produces "$1"-style placeholders, as used by PostgreSQL
+func Dollar(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+var validTable = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+var columns = []string{
+	"ticket_id", "token", "classification", "error_code", "error",
+	"ticket_status", "ticket_message", "receipt_status", "receipt_message",
+	"attempts", "created_at", "recorded_at", "meta",
+}
+
+// Schema returns the CREATE TABLE statement for table
+func Schema(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	ticket_id       VARCHAR(64),
+	token           VARCHAR(255),
+	classification  VARCHAR(32) NOT NULL,
+	error_code      VARCHAR(64),
+	error           TEXT,
+	ticket_status   VARCHAR(16),
+	ticket_message  TEXT,
+	receipt_status  VARCHAR(16),
+	receipt_message TEXT,
+	attempts        INTEGER NOT NULL,
+	created_at      TIMESTAMP,
+	recorded_at     TIMESTAMP NOT NULL,
+	meta            TEXT
+)`, table)
+}
+
+// Sink is an expo.ResultSink writing every result as a row of a table
+type Sink struct {
+	// Clock stamps recorded_at; nil uses expo.SystemClock
+	Clock expo.Clock
+
+	db     *sql.DB
+	table  string
+	insert string
+}
+
+var _ expo.ResultSink = (*Sink)(nil)
+
+// New creates a Sink writing to table, which must be a plain or schema-qualified identifier
+func New(db *sql.DB, table string, placeholder Placeholder) (*Sink, error) {
+	if !validTable.MatchString(table) {
+		return nil, fmt.Errorf("invalid table name: %q", table)
+	}
+	if placeholder == nil {
+		placeholder = Question
+	}
+
+	params := make([]string, len(columns))
+	for i := range columns {
+		params[i] = placeholder(i + 1)
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), strings.Join(params, ", "))
+
+	return &Sink{db: db, table: table, insert: insert}, nil
+}
+
+// Migrate creates the results table if it does not exist
+func (s *Sink) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, Schema(s.table))
+	return err
+}
+
+// Put inserts the results in a single transaction
+func (s *Sink) Put(ctx context.Context, results []*expo.PushResult) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	clock := s.Clock
+	if clock == nil {
+		clock = expo.SystemClock
+	}
+	now := clock.Now().UTC()
+	for _, result := range results {
+		record := expo.NewResultRecord(result)
+		var createdAt *time.Time
+		if !record.CreatedAt.IsZero() {
+			t := record.CreatedAt.UTC()
+			createdAt = &t
+		}
+		meta, err := metaJSON(record.Meta)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx,
+			nullString(record.TicketID), nullString(record.Token), string(record.Classification),
+			nullString(record.ErrorCode), nullString(record.Error),
+			nullString(record.TicketStatus), nullString(record.TicketMessage),
+			nullString(record.ReceiptStatus), nullString(record.ReceiptMessage),
+			record.Attempts, createdAt, now, meta,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// metaJSON encodes the Meta of a message for the meta column, NULL if it is empty
+func metaJSON(meta map[string]string) (sql.NullString, error) {
+	if len(meta) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return sql.NullString{}, fmt.Errorf("encode meta: %w", err)
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
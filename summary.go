@@ -0,0 +1,159 @@
+package expo
+
+import (
+	"errors"
+	"slices"
+)
+
+// ResultClass is a coarse classification of a PushResult
+type ResultClass string
+
+const (
+	// ClassDelivered means Expo delivered the notification to the provider
+	ClassDelivered ResultClass = "delivered"
+	// ClassPending means the ticket was accepted but no receipt is known yet
+	ClassPending ResultClass = "pending"
+	// ClassNotRegistered means the token is no longer valid and should be deleted
+	ClassNotRegistered ResultClass = "not_registered"
+	// ClassRateExceeded means the notification was throttled and may be retried later
+	ClassRateExceeded ResultClass = "rate_exceeded"
+	// ClassCredentialError means the push credentials of the app are misconfigured
+	ClassCredentialError ResultClass = "credential_error"
+	// ClassFailed covers every other failure
+	ClassFailed ResultClass = "failed"
+)
+
+// Classify returns the classification of the result
+func (r *PushResult) Classify() ResultClass {
+	switch ErrorMsg(r.ErrorCode()) {
+	case ErrorMsgDeviceNotRegistered:
+		return ClassNotRegistered
+	case ErrorMsgRateExceeded:
+		return ClassRateExceeded
+	case ErrorMsgInvalidCredentials, ErrorMsgMismatchSenderID:
+		return ClassCredentialError
+	}
+
+	switch {
+	case r.IsSuccessful():
+		return ClassDelivered
+	case (r.Error == nil || errors.Is(r.Error, ErrReceiptUnknown)) && r.PushTicket != nil && r.PushTicket.IsOk():
+		return ClassPending
+	default:
+		return ClassFailed
+	}
+}
+
+// ResultSummary aggregates a set of results by classification
+type ResultSummary struct {
+	Total            int
+	Delivered        int
+	Pending          int
+	NotRegistered    int
+	RateExceeded     int
+	CredentialErrors int
+	Failed           int
+	// ByErrorCode counts the results carrying each Expo error code
+	ByErrorCode map[string]int
+	// TokensToDelete are the tokens reported as no longer registered
+	TokensToDelete []Token
+}
+
+// SummarizeResults counts results by classification and collects the tokens
+// that should be removed from your database
+func SummarizeResults(results []*PushResult) ResultSummary {
+	summary := ResultSummary{ByErrorCode: make(map[string]int)}
+	seen := make(map[Token]bool)
+
+	for _, result := range results {
+		summary.Total++
+		if code := result.ErrorCode(); code != "" {
+			summary.ByErrorCode[code]++
+		}
+
+		switch result.Classify() {
+		case ClassDelivered:
+			summary.Delivered++
+		case ClassPending:
+			summary.Pending++
+		case ClassNotRegistered:
+			summary.NotRegistered++
+			if result.Token != nil && !seen[*result.Token] {
+				seen[*result.Token] = true
+				summary.TokensToDelete = append(summary.TokensToDelete, *result.Token)
+			}
+		case ClassRateExceeded:
+			summary.RateExceeded++
+		case ClassCredentialError:
+			summary.CredentialErrors++
+		default:
+			summary.Failed++
+		}
+	}
+
+	return summary
+}
+
+// ResultDiff compares the results of two attempts at sending to the same
+// recipients. Results are those of the current attempt, except Dropped.
+type ResultDiff struct {
+	// Recovered failed before and succeeded now
+	Recovered []*PushResult
+	// StillFailing failed in both attempts
+	StillFailing []*PushResult
+	// Regressed succeeded before and failed now
+	Regressed []*PushResult
+	// StillSucceeding succeeded in both attempts
+	StillSucceeding []*PushResult
+	// Added are recipients only found in the current attempt
+	Added []*PushResult
+	// Dropped are the previous results of recipients missing from the current attempt
+	Dropped []*PushResult
+}
+
+// succeeded returns true if the notification was delivered or is pending its receipt
+func (r *PushResult) succeeded() bool {
+	class := r.Classify()
+	return class == ClassDelivered || class == ClassPending
+}
+
+// CompareResults matches the results of two attempts by recipient token and
+// reports how each recipient fared. Results for a token addressed more than
+// once are matched in order.
+func CompareResults(previous, current []*PushResult) ResultDiff {
+	var diff ResultDiff
+	earlier := make(map[Token][]*PushResult)
+	for _, result := range previous {
+		if result.Token != nil {
+			earlier[*result.Token] = append(earlier[*result.Token], result)
+		}
+	}
+
+	for _, result := range current {
+		var before *PushResult
+		if result.Token != nil {
+			if queue := earlier[*result.Token]; len(queue) > 0 {
+				before, earlier[*result.Token] = queue[0], queue[1:]
+			}
+		}
+		switch {
+		case before == nil:
+			diff.Added = append(diff.Added, result)
+		case before.succeeded() && result.succeeded():
+			diff.StillSucceeding = append(diff.StillSucceeding, result)
+		case before.succeeded():
+			diff.Regressed = append(diff.Regressed, result)
+		case result.succeeded():
+			diff.Recovered = append(diff.Recovered, result)
+		default:
+			diff.StillFailing = append(diff.StillFailing, result)
+		}
+	}
+
+	for _, result := range previous {
+		if result.Token == nil || slices.Contains(earlier[*result.Token], result) {
+			diff.Dropped = append(diff.Dropped, result)
+		}
+	}
+	return diff
+}
@@ -0,0 +1,231 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ReceiptRetention is how long Expo keeps push receipts after a ticket is issued
+const ReceiptRetention = 24 * time.Hour
+
+// ErrReceiptExpired is reported for tickets whose receipts were not fetched
+// within ReceiptRetention and have been deleted by Expo
+var ErrReceiptExpired = errors.New("push receipt expired")
+
+// ErrReceiptUnknown is reported for accepted tickets whose receipt could not
+// be fetched because the workflow was canceled
+var ErrReceiptUnknown = errors.New("push receipt unknown")
+
+// Ticket is a push ticket awaiting its receipt
+type Ticket struct {
+	ID        string    `json:"id"`
+	Token     *Token    `json:"token,omitempty"`
+	Message   *Message  `json:"message,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Meta is stored apart from the message, whose Meta is not encoded
+	Meta map[string]string `json:"meta,omitempty"`
+	// WorkflowID is the ID of the workflow that sent the ticket, if any
+	WorkflowID string `json:"workflowId,omitempty"`
+}
+
+// Expired returns true if the ticket's receipt is past Expo's retention window
+func (t *Ticket) Expired(now time.Time) bool {
+	return now.Sub(t.CreatedAt) > ReceiptRetention
+}
+
+// TicketStore persists tickets until their receipts have been fetched
+type TicketStore interface {
+	Add(ctx context.Context, tickets []*Ticket) error
+	Pending(ctx context.Context) ([]*Ticket, error)
+	Remove(ctx context.Context, ids []string) error
+}
+
+// MemoryTicketStore is a TicketStore kept in memory
+type MemoryTicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]*Ticket
+}
+
+// NewMemoryTicketStore creates an empty MemoryTicketStore
+func NewMemoryTicketStore() *MemoryTicketStore {
+	return &MemoryTicketStore{tickets: make(map[string]*Ticket)}
+}
+
+func (s *MemoryTicketStore) Add(ctx context.Context, tickets []*Ticket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ticket := range tickets {
+		s.tickets[ticket.ID] = ticket
+	}
+	return nil
+}
+
+func (s *MemoryTicketStore) Pending(ctx context.Context) ([]*Ticket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make([]*Ticket, 0, len(s.tickets))
+	for _, ticket := range s.tickets {
+		pending = append(pending, ticket)
+	}
+	slices.SortFunc(pending, func(a, b *Ticket) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return pending, nil
+}
+
+func (s *MemoryTicketStore) Remove(ctx context.Context, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		delete(s.tickets, id)
+	}
+	return nil
+}
+
+// ReceiptTracker remembers push tickets and fetches their receipts later,
+// reporting tickets whose receipts expired before they were checked
+type ReceiptTracker struct {
+	client *Client
+	store  TicketStore
+}
+
+// NewReceiptTracker creates a ReceiptTracker. If store is nil, tickets are kept in memory.
+func NewReceiptTracker(client *Client, store TicketStore) *ReceiptTracker {
+	if store == nil {
+		store = NewMemoryTicketStore()
+	}
+	return &ReceiptTracker{client: client, store: store}
+}
+
+// Track stores the successful tickets among responses
+func (t *ReceiptTracker) Track(ctx context.Context, responses []*MessageResponse) error {
+	now := t.client.cnf.Clock.Now()
+	var tickets []*Ticket
+	for _, response := range responses {
+		if response.IsOk() && response.ID != "" {
+			tickets = append(tickets, &Ticket{
+				ID:        response.ID,
+				Token:     response.Token,
+				Message:   response.MessageItem,
+				Meta:      response.MessageItem.meta(),
+				CreatedAt: now,
+			})
+		}
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+	return t.store.Add(ctx, tickets)
+}
+
+// Check fetches the receipts of all tracked tickets. Tickets with a receipt,
+// and tickets whose receipt expired (reported with ErrReceiptExpired), are
+// returned and forgotten; tickets whose receipt is not available yet stay tracked.
+// With a ReceiptSchedule set on the client, tickets are only fetched once their
+// first check is due, and tickets the schedule gave up on before their receipt
+// expired are reported with ErrReceiptUnavailable.
+func (t *ReceiptTracker) Check(ctx context.Context) ([]*PushResult, error) {
+	pending, err := t.store.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := t.client.cnf.Clock.Now()
+	schedule := t.client.trackerSchedule()
+	var results []*PushResult
+	var done []string
+	var fetch []*Ticket
+	for _, ticket := range pending {
+		if ticket.Expired(now) || schedule.GaveUp(ticket.CreatedAt, now) {
+			result := ticket.result(nil)
+			if !ticket.Expired(now) {
+				result.Error = ErrReceiptUnavailable
+			}
+			results = append(results, result)
+			done = append(done, ticket.ID)
+			continue
+		}
+		if schedule.Due(ticket.CreatedAt, now) {
+			fetch = append(fetch, ticket)
+		}
+	}
+
+	for chunk := range slices.Chunk(fetch, t.client.maxReceiptBatch()) {
+		ids := make([]string, len(chunk))
+		for i, ticket := range chunk {
+			ids[i] = ticket.ID
+		}
+		receipts, err := t.client.GetPushReceipts(ctx, ids)
+		if err != nil {
+			t.store.Remove(ctx, done)
+			return results, err
+		}
+		for _, ticket := range chunk {
+			if receipt, ok := receipts[ticket.ID]; ok {
+				results = append(results, ticket.result(receipt))
+				done = append(done, ticket.ID)
+			}
+		}
+	}
+
+	if err := t.store.Remove(ctx, done); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// Resolve records receipts obtained without polling, e.g. from a webhook. The
+// tickets they belong to are returned as results and forgotten; receipts for
+// tickets that are not tracked are returned with only their ticket ID set.
+func (t *ReceiptTracker) Resolve(ctx context.Context, receipts map[string]*PushReceipt) ([]*PushResult, error) {
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+	pending, err := t.store.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make(map[string]*Ticket, len(receipts))
+	for _, ticket := range pending {
+		if _, ok := receipts[ticket.ID]; ok {
+			tickets[ticket.ID] = ticket
+		}
+	}
+	results := make([]*PushResult, 0, len(receipts))
+	done := make([]string, 0, len(tickets))
+	for _, id := range slices.Sorted(maps.Keys(receipts)) {
+		ticket, ok := tickets[id]
+		if !ok {
+			ticket = &Ticket{ID: id}
+		} else {
+			done = append(done, id)
+		}
+		results = append(results, ticket.result(receipts[id]))
+	}
+	return results, t.store.Remove(ctx, done)
+}
+
+// result builds the PushResult for the ticket; a nil receipt means it expired
+func (t *Ticket) result(receipt *PushReceipt) *PushResult {
+	result := &PushResult{
+		TicketID:    t.ID,
+		Message:     t.Message,
+		Token:       t.Token,
+		Meta:        t.Meta,
+		PushReceipt: receipt,
+		CreatedAt:   t.CreatedAt,
+	}
+	switch {
+	case receipt == nil:
+		result.Error = ErrReceiptExpired
+	case !receipt.IsOk():
+		result.Error = fmt.Errorf("push receipt error: %s", RedactToken(receipt.Message))
+	}
+	return result
+}
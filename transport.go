@@ -0,0 +1,31 @@
+package expo
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// withTransportSettings returns a copy of client whose transport uses
+// tlsConfig, if not nil, and attempts HTTP/2 if forceHTTP2 is set. A client
+// whose transport is not an *http.Transport is returned as is.
+func withTransportSettings(client *http.Client, tlsConfig *tls.Config, forceHTTP2 bool) *http.Client {
+	var transport *http.Transport
+	switch t := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return client
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	if forceHTTP2 {
+		transport.ForceAttemptHTTP2 = true
+	}
+
+	clientCopy := *client
+	clientCopy.Transport = transport
+	return &clientCopy
+}
@@ -0,0 +1,282 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// maxEstimatedPayloadSize leaves some buffer below Expo's 4096 byte limit for the JSON structure
+	maxEstimatedPayloadSize = 4000
+	// payloadWarningSize is the estimated size above which a payload is reported as close to the limit
+	payloadWarningSize = 3500
+)
+
+// ValidationMode controls how Publish validates messages before sending them
+type ValidationMode int
+
+const (
+	// ValidationBasic, the default, only rejects messages without recipients
+	// and empty push tokens
+	ValidationBasic ValidationMode = iota
+	// ValidationStrict rejects the whole request if any message is invalid
+	ValidationStrict
+	// ValidationLenient removes invalid tokens and reports each of them as an error response
+	ValidationLenient
+	// ValidationOff sends messages as they are
+	ValidationOff
+)
+
+// Severity indicates how serious a validation issue is
+type Severity int
+
+const (
+	// SeverityWarning marks an issue that does not prevent sending
+	SeverityWarning Severity = iota
+	// SeverityError marks an issue that makes the message invalid
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// ValidationIssue describes a single problem found in a message
+type ValidationIssue struct {
+	// Index is the position of the message in the validated slice
+	Index    int
+	Field    string
+	Severity Severity
+	Message  string
+}
+
+func (i ValidationIssue) Error() string {
+	return fmt.Sprintf("message %d: %s: %s", i.Index, i.Field, i.Message)
+}
+
+// HasErrors returns true if any of the issues has error severity
+func HasErrors(issues []ValidationIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateMessages validates every message and returns all issues found, in message order
+func ValidateMessages(msgs []*Message) []ValidationIssue {
+	var issues []ValidationIssue
+	for i, msg := range msgs {
+		issues = append(issues, validateMessage(i, msg, 0)...)
+	}
+	return issues
+}
+
+// validateMessages is like ValidateMessages but checks payloads against MaxPayloadBytes, if set
+func (c *Client) validateMessages(msgs []*Message) []ValidationIssue {
+	var issues []ValidationIssue
+	for i, msg := range msgs {
+		issues = append(issues, validateMessage(i, msg, c.cnf.MaxPayloadBytes)...)
+	}
+	return issues
+}
+
+// ValidateMessage validates a message according to Expo requirements
+func ValidateMessage(msg *Message) error {
+	for _, issue := range validateMessage(0, msg, 0) {
+		if issue.Severity == SeverityError {
+			return errors.New(issue.Message)
+		}
+	}
+	return nil
+}
+
+// validateMessage checks msg, comparing its exact payload size with maxPayload
+// if positive, or else an estimate of it with Expo's limit
+func validateMessage(index int, msg *Message, maxPayload int) []ValidationIssue {
+	var issues []ValidationIssue
+	report := func(field string, severity Severity, format string, args ...any) {
+		issues = append(issues, ValidationIssue{
+			Index:    index,
+			Field:    field,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if msg == nil {
+		report("message", SeverityError, "missing message")
+		return issues
+	}
+
+	if len(msg.To) == 0 {
+		report("to", SeverityError, "message must have at least one recipient")
+	}
+
+	if maxPayload > 0 {
+		size, err := msg.PayloadSize()
+		switch {
+		case err != nil:
+			report("payload", SeverityError, "cannot encode message: %v", err)
+		case size > maxPayload:
+			report("payload", SeverityError, "message payload too large (%d bytes, maximum %d)", size, maxPayload)
+		case size > maxPayload-maxPayload/8:
+			report("payload", SeverityWarning, "message payload close to the limit (%d bytes, maximum %d)", size, maxPayload)
+		}
+	} else {
+		// Check payload size (rough estimate - actual calculation would be more complex)
+		// The documentation mentions 4096 bytes maximum
+		estimatedSize := len(msg.Title) + len(msg.Body)
+		if msg.Data != nil {
+			for k, v := range msg.Data {
+				estimatedSize += len(k) + len(v)
+			}
+		}
+		if estimatedSize > maxEstimatedPayloadSize {
+			report("payload", SeverityError, "message payload too large (estimated %d bytes, maximum ~%d)", estimatedSize, maxEstimatedPayloadSize)
+		} else if estimatedSize > payloadWarningSize {
+			report("payload", SeverityWarning, "message payload close to the limit (estimated %d bytes, maximum ~%d)", estimatedSize, maxEstimatedPayloadSize)
+		}
+	}
+
+	if !msg.Priority.Valid() {
+		report("priority", SeverityError, "invalid priority %q, must be one of %q, %q or %q",
+			msg.Priority, DefaultPriority, NormalPriority, HighPriority)
+	}
+	if !msg.InterruptionLevel.Valid() {
+		report("interruptionLevel", SeverityError, "invalid interruption level %q, must be one of %q, %q, %q or %q",
+			msg.InterruptionLevel, InterruptionLevelPassive, InterruptionLevelActive,
+			InterruptionLevelTimeSensitive, InterruptionLevelCritical)
+	}
+
+	validateSound(msg.Sound, report)
+
+	if msg.TTL < 0 {
+		report("ttl", SeverityError, "ttl must not be negative, got %d", msg.TTL)
+	}
+	if msg.TTL != 0 && msg.Expiration != 0 {
+		report("expiration", SeverityError, "ttl and expiration must not both be set")
+	}
+
+	if msg.RelevanceScore != nil && (*msg.RelevanceScore < 0 || *msg.RelevanceScore > 1) {
+		report("relevanceScore", SeverityError, "relevance score must be between 0 and 1, got %g", *msg.RelevanceScore)
+	}
+
+	// Validate tokens
+	for i, token := range msg.To {
+		if token == nil {
+			report(fmt.Sprintf("to[%d]", i), SeverityError, "missing push token")
+		} else if !IsPushTokenValid(string(*token)) {
+			report(fmt.Sprintf("to[%d]", i), SeverityError, "invalid push token: %s", token.Redacted())
+		}
+	}
+
+	return issues
+}
+
+// validateBasic returns an issue for the first message without recipients or
+// with a nil or empty push token, if any
+func validateBasic(msgs []*Message) error {
+	for i, msg := range msgs {
+		if len(msg.To) == 0 {
+			return ValidationIssue{Index: i, Field: "to", Severity: SeverityError, Message: "no recipients"}
+		}
+		for j, token := range msg.To {
+			switch {
+			case token == nil:
+				return ValidationIssue{Index: i, Field: fmt.Sprintf("to[%d]", j), Severity: SeverityError, Message: "missing push token"}
+			case *token == "":
+				return ValidationIssue{Index: i, Field: fmt.Sprintf("to[%d]", j), Severity: SeverityError, Message: "invalid push token"}
+			}
+		}
+	}
+	return nil
+}
+
+// publishLenient sends copies of msgs without their invalid tokens and reports
+// every removed token as an error response in its original position
+func (c *Client) publishLenient(ctx context.Context, msgs []*Message) ([]*MessageResponse, error) {
+	filtered, _ := WithoutInvalidTokens(msgs)
+
+	var sendable []*Message
+	for _, msg := range filtered {
+		if len(msg.To) > 0 {
+			sendable = append(sendable, msg)
+		}
+	}
+
+	var sent []*MessageResponse
+	if len(sendable) > 0 {
+		var err error
+		if sent, err = c.send(ctx, sendable); err != nil {
+			return nil, err
+		}
+	}
+
+	var responses []*MessageResponse
+	for _, msg := range msgs {
+		for _, token := range msg.To {
+			if token != nil && IsPushTokenValid(string(*token)) {
+				response := sent[0]
+				sent = sent[1:]
+				response.MessageItem = msg
+				responses = append(responses, response)
+				continue
+			}
+			responses = append(responses, &MessageResponse{
+				MessageItem: msg,
+				Token:       token,
+				Status:      "error",
+				Message:     "invalid push token",
+				Details:     Data{"error": string(ErrorMsgInvalidPushToken)},
+			})
+		}
+	}
+	return responses, nil
+}
+
+// iosSoundExtensions are the audio file types iOS plays for notifications
+var iosSoundExtensions = []string{".wav", ".caf", ".aiff", ".aif"}
+
+// androidResourceName matches the names Android accepts for raw resources
+var androidResourceName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// validateSound warns about sound names that a platform will not play, which
+// results in a silent notification rather than an error from Expo
+func validateSound(sound string, report func(field string, severity Severity, format string, args ...any)) {
+	if sound == "" || sound == "default" {
+		return
+	}
+	if strings.EqualFold(sound, "default") {
+		report("sound", SeverityWarning, "sound %q is not recognized, use \"default\" for the default sound", sound)
+		return
+	}
+	if strings.ContainsAny(sound, `/\`) {
+		report("sound", SeverityWarning, "sound %q must be the file name of a sound bundled with the app, not a path", sound)
+		return
+	}
+
+	ext := path.Ext(sound)
+	switch {
+	case ext == "":
+		report("sound", SeverityWarning, "sound %q has no extension, which iOS needs to find the bundled file (.wav, .caf or .aiff)", sound)
+	case !slices.Contains(iosSoundExtensions, strings.ToLower(ext)):
+		report("sound", SeverityWarning, "sound %q is not a .wav, .caf or .aiff file, which iOS will not play", sound)
+	}
+	if name := strings.TrimSuffix(sound, ext); !androidResourceName.MatchString(name) {
+		report("sound", SeverityWarning, "sound %q is not a valid Android resource name (lowercase letters, digits and underscores)", sound)
+	}
+}
@@ -0,0 +1,136 @@
+package expo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ReceiptSignatureHeader carries the HMAC-SHA256 of a webhook body, as "sha256=<hex>"
+	ReceiptSignatureHeader = "Expo-Signature"
+	// maxWebhookBodySize bounds the receipt payloads accepted by ReceiptWebhookHandler
+	maxWebhookBodySize = 10 << 20
+)
+
+// ErrWebhookNotConfigured is returned for webhook requests received by a
+// ReceiptWebhookHandler with neither a secret nor a verifier, unless unsigned
+// webhooks were explicitly allowed
+var ErrWebhookNotConfigured = errors.New("webhook signature verification is not configured")
+
+// ReceiptWebhookHandler is an http.Handler receiving push receipts delivered by
+// webhook instead of polling. The payload has the shape of a getReceipts
+// response, {"data": {"<ticket id>": {"status": "ok"}}}. Receipts are matched
+// to the tickets of a ReceiptTracker, if one is given, and the results are
+// handed to a ResultSink.
+type ReceiptWebhookHandler struct {
+	secret   []byte
+	verifier *WebhookVerifier
+	tracker  *ReceiptTracker
+	sink     ResultSink
+	unsigned bool
+}
+
+// NewReceiptWebhookHandler creates a ReceiptWebhookHandler accepting requests
+// signed with secret; tracker and sink may be nil. If secret is empty, e.g.
+// because an environment variable is missing, every request is refused with
+// ErrWebhookNotConfigured rather than accepted unverified.
+func NewReceiptWebhookHandler(secret []byte, tracker *ReceiptTracker, sink ResultSink) *ReceiptWebhookHandler {
+	return &ReceiptWebhookHandler{secret: secret, tracker: tracker, sink: sink}
+}
+
+// NewVerifiedReceiptWebhookHandler creates a ReceiptWebhookHandler accepting
+// only requests whose signature, made by a WebhookSigner, passes verifier,
+// which also rejects stale and replayed requests
+func NewVerifiedReceiptWebhookHandler(verifier *WebhookVerifier, tracker *ReceiptTracker, sink ResultSink) *ReceiptWebhookHandler {
+	return &ReceiptWebhookHandler{verifier: verifier, tracker: tracker, sink: sink}
+}
+
+// WithUnsignedWebhooks makes a handler without a secret or verifier accept
+// unsigned requests, e.g. in tests, and returns the handler. Never use it for
+// an endpoint reachable by others, as anyone could then forge receipts.
+func (h *ReceiptWebhookHandler) WithUnsignedWebhooks() *ReceiptWebhookHandler {
+	h.unsigned = true
+	return h
+}
+
+func (h *ReceiptWebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := h.verify(body, r.Header.Get(ReceiptSignatureHeader)); errors.Is(err, ErrWebhookNotConfigured) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var payload PushReceiptResponse
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "invalid receipt payload", http.StatusBadRequest)
+		return
+	}
+	receipts := make(map[string]*PushReceipt, len(payload.Data))
+	for id, receipt := range payload.Data {
+		if receipt != nil {
+			receipts[id] = receipt
+		}
+	}
+
+	var results []*PushResult
+	if h.tracker != nil {
+		results, err = h.tracker.Resolve(r.Context(), receipts)
+	} else {
+		for id, receipt := range receipts {
+			results = append(results, (&Ticket{ID: id}).result(receipt))
+		}
+	}
+	if err == nil && h.sink != nil && len(results) > 0 {
+		err = h.sink.Put(r.Context(), results)
+	}
+	if err != nil {
+		http.Error(w, "failed to record receipts", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks the signature of body with the verifier or secret of the handler
+func (h *ReceiptWebhookHandler) verify(body []byte, signature string) error {
+	switch {
+	case h.verifier != nil:
+		return h.verifier.Verify(body, signature)
+	case len(h.secret) == 0 && h.unsigned:
+		return nil
+	case len(h.secret) == 0:
+		return ErrWebhookNotConfigured
+	}
+	sum, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return errors.New("missing signature")
+	}
+	got, err := hex.DecodeString(sum)
+	if err != nil {
+		return errors.New("malformed signature")
+	}
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package expo_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	expo "dezeto/expo-push-notification"
+)
+
+func TestReceiptWebhookHandler(t *testing.T) {
+	const body = `{"data":{"ticket-1":{"status":"ok"}}}`
+	secret := []byte("webhook-secret")
+	sign := func(key []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name       string
+		handler    func(sink expo.ResultSink) *expo.ReceiptWebhookHandler
+		signature  string
+		wantStatus int
+	}{
+		{
+			name: "signed",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(secret, nil, sink)
+			},
+			signature:  sign(secret),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "wrong secret",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(secret, nil, sink)
+			},
+			signature:  sign([]byte("forged")),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "unsigned",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(secret, nil, sink)
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "empty secret",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(nil, nil, sink)
+			},
+			signature:  sign(nil),
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "nil verifier",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewVerifiedReceiptWebhookHandler(nil, nil, sink)
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name: "unsigned webhooks allowed",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(nil, nil, sink).WithUnsignedWebhooks()
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name: "secret still checked when unsigned webhooks are allowed",
+			handler: func(sink expo.ResultSink) *expo.ReceiptWebhookHandler {
+				return expo.NewReceiptWebhookHandler(secret, nil, sink).WithUnsignedWebhooks()
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received []*expo.PushResult
+			sink := expo.ResultSinkFunc(func(ctx context.Context, results []*expo.PushResult) error {
+				received = append(received, results...)
+				return nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/expo", strings.NewReader(body))
+			if tt.signature != "" {
+				req.Header.Set(expo.ReceiptSignatureHeader, tt.signature)
+			}
+			rec := httptest.NewRecorder()
+			tt.handler(sink).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			accepted := tt.wantStatus == http.StatusNoContent
+			if accepted != (len(received) == 1) {
+				t.Errorf("sink received %d results", len(received))
+			}
+			if accepted && received[0].TicketID != "ticket-1" {
+				t.Errorf("result = %+v", received[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package expo
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MetaTimeZone is the Message.Meta key holding the IANA time zone of the
+// message's recipients, such as "Europe/Paris", for DeliveryWindow
+const MetaTimeZone = "timeZone"
+
+// DeliveryWindow is the time of day during which messages may be delivered
+// in their recipients' time zone, e.g. 09:00 to 21:00 for marketing pushes
+// or to respect legal quiet hours. Start and End are offsets from midnight
+// in wall clock time; an End before Start wraps past midnight, and equal
+// offsets allow any time.
+type DeliveryWindow struct {
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone of messages without MetaTimeZone; nil means UTC
+	Location *time.Location
+}
+
+// DeferredMessage is a message held back until its delivery window opens
+type DeferredMessage struct {
+	Message *Message
+	SendAt  time.Time
+}
+
+// Validate reports offsets outside of a day
+func (w DeliveryWindow) Validate() error {
+	var errs []error
+	if w.Start < 0 || w.Start >= 24*time.Hour {
+		errs = append(errs, fmt.Errorf("Start must be between 0 and 24h, got %s", w.Start))
+	}
+	if w.End < 0 || w.End >= 24*time.Hour {
+		errs = append(errs, fmt.Errorf("End must be between 0 and 24h, got %s", w.End))
+	}
+	return errors.Join(errs...)
+}
+
+// NextOpen returns t if the window is open at t in loc, or else the time it next opens
+func (w DeliveryWindow) NextOpen(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	offset := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second +
+		time.Duration(local.Nanosecond())
+	if w.contains(offset) {
+		return t
+	}
+
+	// Closed means before Start, either earlier today or after End, which
+	// for a window not wrapping past midnight is before Start tomorrow
+	day := local
+	if offset >= w.Start {
+		day = local.AddDate(0, 0, 1)
+	}
+	start := w.Start.Round(time.Second)
+	hours, minutes, seconds := int(start/time.Hour), int(start%time.Hour/time.Minute), int(start%time.Minute/time.Second)
+	return time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, seconds, 0, loc)
+}
+
+// contains reports whether the offset from midnight is within the window
+func (w DeliveryWindow) contains(offset time.Duration) bool {
+	switch {
+	case w.Start == w.End:
+		return true
+	case w.Start < w.End:
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// Partition splits msgs into those that may be delivered at now and those to
+// hold back, with the time their window opens. The time zone of each message
+// is taken from its MetaTimeZone, or else Location. Messages whose time zone
+// cannot be loaded are left out of both and reported in the error, so that
+// they are never sent at the wrong hour. Nil messages are skipped.
+func (w DeliveryWindow) Partition(msgs []*Message, now time.Time) ([]*Message, []DeferredMessage, error) {
+	var due []*Message
+	var deferred []DeferredMessage
+	var errs []error
+	locations := make(map[string]*time.Location)
+
+	for i, msg := range msgs {
+		if msg == nil {
+			continue
+		}
+		loc := w.Location
+		if loc == nil {
+			loc = time.UTC
+		}
+		if name := msg.Meta[MetaTimeZone]; name != "" {
+			if locations[name] == nil {
+				l, err := time.LoadLocation(name)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("message %d: %w", i, err))
+					continue
+				}
+				locations[name] = l
+			}
+			loc = locations[name]
+		}
+
+		if sendAt := w.NextOpen(now, loc); sendAt.Equal(now) {
+			due = append(due, msg)
+		} else {
+			deferred = append(deferred, DeferredMessage{Message: msg, SendAt: sendAt})
+		}
+	}
+	return due, deferred, errors.Join(errs...)
+}
@@ -3,6 +3,7 @@ package expo
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -10,9 +11,16 @@ import (
 type PushResult struct {
 	TicketID    string
 	Message     *Message
+	Token       *Token
 	PushTicket  *MessageResponse
 	PushReceipt *PushReceipt
-	Error       error
+	// CreatedAt is when the push ticket was issued
+	CreatedAt time.Time
+	// Attempts is the number of times the notification was sent
+	Attempts int
+	Error    error
+	// Meta is the Meta of the message, also kept by tickets that do not keep the message
+	Meta map[string]string
 }
 
 // IsSuccessful returns true if the push was successful (ticket OK and receipt OK)
@@ -22,6 +30,17 @@ func (r *PushResult) IsSuccessful() bool {
 		r.PushReceipt != nil && r.PushReceipt.IsOk()
 }
 
+// ErrorCode returns the Expo error code reported in the receipt or ticket details, if any
+func (r *PushResult) ErrorCode() string {
+	if r.PushReceipt != nil && r.PushReceipt.ErrorCode() != "" {
+		return string(r.PushReceipt.ErrorCode())
+	}
+	if r.PushTicket != nil {
+		return string(r.PushTicket.ErrorCode())
+	}
+	return ""
+}
+
 // ShouldRetryToken returns true if this token should be retried later
 func (r *PushResult) ShouldRetryToken() bool {
 	if r.PushReceipt != nil && r.PushReceipt.Details != nil {
@@ -33,8 +52,45 @@ func (r *PushResult) ShouldRetryToken() bool {
 }
 
 // SendPushNotificationsWithReceipts sends push notifications and waits for receipts
-// This implements the complete workflow recommended by Expo documentation
+// This implements the complete workflow recommended by Expo documentation.
+// Receipts are checked once after receiptDelay or, if it is zero, as set by
+// WithReceiptSchedule, defaulting to a single check after 15 minutes.
 func (c *Client) SendPushNotificationsWithReceipts(ctx context.Context, messages []*Message, receiptDelay time.Duration) ([]*PushResult, error) {
+	return c.runWorkflow(ctx, messages, receiptDelay, nil)
+}
+
+// runWorkflow implements SendPushNotificationsWithReceipts, reporting progress to h if it is not nil
+func (c *Client) runWorkflow(ctx context.Context, messages []*Message, receiptDelay time.Duration, h *WorkflowHandle) ([]*PushResult, error) {
+	results, err := c.sendAndCollect(ctx, messages, receiptDelay, h)
+	return c.finishWorkflow(ctx, results, err, receiptDelay, h)
+}
+
+// finishTimeout bounds the result sink and token cleanup calls that end a workflow
+const finishTimeout = 30 * time.Second
+
+// finishWorkflow resends recipients with retryable receipt errors unless err
+// is set, then passes the results to the result sink and token cleanup. These
+// run even if ctx is done, e.g. after fetching receipts on cancellation, so
+// that the results are not lost.
+func (c *Client) finishWorkflow(ctx context.Context, results []*PushResult, err error, receiptDelay time.Duration, h *WorkflowHandle) ([]*PushResult, error) {
+	if err == nil {
+		err = c.retryReceiptErrors(ctx, results, receiptDelay, h)
+	}
+	if results != nil {
+		finishCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finishTimeout)
+		defer cancel()
+		if sinkErr := c.emitResults(finishCtx, results); err == nil {
+			err = sinkErr
+		}
+		if cleanupErr := c.cleanupTokens(finishCtx, results); err == nil {
+			err = cleanupErr
+		}
+	}
+	return results, err
+}
+
+// sendAndCollect publishes messages, waits for the receipts as scheduled and matches them to the results
+func (c *Client) sendAndCollect(ctx context.Context, messages []*Message, receiptDelay time.Duration, h *WorkflowHandle) ([]*PushResult, error) {
 	// Step 1: Send push notifications
 	responses, err := c.Publish(ctx, messages)
 	if err != nil {
@@ -42,101 +98,242 @@ func (c *Client) SendPushNotificationsWithReceipts(ctx context.Context, messages
 	}
 
 	// Step 2: Collect successful ticket IDs
+	createdAt := c.cnf.Clock.Now()
 	var ticketIDs []string
 	results := make([]*PushResult, len(responses))
 
 	for i, response := range responses {
 		result := &PushResult{
 			Message:    response.MessageItem,
+			Token:      response.Token,
+			Meta:       response.MessageItem.meta(),
 			PushTicket: response,
+			CreatedAt:  createdAt,
+			Attempts:   1,
 		}
 
 		if response.IsOk() && response.ID != "" {
 			result.TicketID = response.ID
 			ticketIDs = append(ticketIDs, response.ID)
+		} else if response.Err != nil {
+			result.Error = response.Err
 		} else {
-			result.Error = fmt.Errorf("push ticket error: %s", response.Message)
+			result.Error = fmt.Errorf("push ticket error: %s", RedactToken(response.Message))
 		}
 
 		results[i] = result
 	}
-
-	// Step 3: Wait for receipts (recommended: 15 minutes)
-	if receiptDelay == 0 {
-		receiptDelay = 15 * time.Minute
-	}
+	h.update(func(p *WorkflowProgress) {
+		p.Tickets += len(ticketIDs)
+		p.Failed += len(results) - len(ticketIDs)
+	})
 
 	if len(ticketIDs) == 0 {
 		return results, nil
 	}
+	return results, c.awaitReceipts(ctx, results, receiptDelay, h)
+}
 
-	// Wait for receipts to be available
-	select {
-	case <-ctx.Done():
-		return results, ctx.Err()
-	case <-time.After(receiptDelay):
-		// Continue to fetch receipts
+// awaitReceipts waits for the receipts of the results with a ticket ID as
+// scheduled and sets them on the results. With a workflow ID on ctx, the
+// tickets are kept in the workflow store until their receipts are fetched.
+func (c *Client) awaitReceipts(ctx context.Context, results []*PushResult, receiptDelay time.Duration, h *WorkflowHandle) error {
+	var ticketIDs []string
+	var createdAt time.Time
+	pending := make(map[string]*PushResult, len(results))
+	for _, result := range results {
+		if result.TicketID == "" {
+			continue
+		}
+		ticketIDs = append(ticketIDs, result.TicketID)
+		pending[result.TicketID] = result
+		if createdAt.IsZero() || result.CreatedAt.Before(createdAt) {
+			createdAt = result.CreatedAt
+		}
 	}
-
-	// Step 4: Fetch push receipts
-	receipts, err := c.GetPushReceipts(ctx, ticketIDs)
-	if err != nil {
-		return results, fmt.Errorf("failed to fetch push receipts: %w", err)
+	if err := c.saveWorkflow(ctx, ticketIDs, pending); err != nil {
+		return err
 	}
 
-	// Step 5: Match receipts to results
-	for _, result := range results {
-		if result.TicketID != "" {
-			if receipt, exists := receipts[result.TicketID]; exists {
-				result.PushReceipt = receipt
-
-				// Check for specific errors in receipts
-				if !receipt.IsOk() {
-					result.Error = fmt.Errorf("push receipt error: %s", receipt.Message)
-				}
+	// Step 3: Wait for receipts (recommended: 15 minutes) and fetch them,
+	// checking again as scheduled for receipts that are not available yet
+	schedule := c.workflowSchedule(receiptDelay)
+	for check := 0; len(ticketIDs) > 0; check++ {
+		at, ok := schedule.checkAt(createdAt, check)
+		if !ok {
+			break
+		}
+		h.update(func(p *WorkflowProgress) {
+			p.Stage = StageWaitingForReceipts
+			p.ReceiptsDueAt = at
+		})
+		select {
+		case <-ctx.Done():
+			return c.fetchOnCancel(ctx, ticketIDs, pending, h)
+		case <-c.cnf.Clock.After(at.Sub(c.cnf.Clock.Now())):
+			// Continue to fetch receipts
+		}
+
+		// Step 4: Fetch push receipts
+		h.update(func(p *WorkflowProgress) {
+			p.Stage = StageFetchingReceipts
+		})
+		missing, err := c.collectReceipts(ctx, ticketIDs, pending, h)
+		if err != nil {
+			if ctx.Err() != nil {
+				return c.fetchOnCancel(ctx, missing, pending, h)
 			}
+			return fmt.Errorf("failed to fetch push receipts: %w", err)
 		}
+		ticketIDs = missing
 	}
 
-	return results, nil
+	for _, id := range ticketIDs {
+		result := pending[id]
+		if c.cnf.Clock.Now().Sub(result.CreatedAt) > ReceiptRetention {
+			result.Error = ErrReceiptExpired
+		} else {
+			result.Error = ErrReceiptUnavailable
+		}
+	}
+	// The schedule has given up on the rest, so resuming could not fetch them either
+	c.forgetWorkflowTickets(ctx, ticketIDs)
+	return nil
 }
 
-// ValidateMessage validates a message according to Expo requirements
-func ValidateMessage(msg *Message) error {
-	if len(msg.To) == 0 {
-		return fmt.Errorf("message must have at least one recipient")
-	}
+// collectReceipts fetches the receipts of ticketIDs and sets them on their
+// results in pending. It returns the IDs whose receipts are not available yet,
+// or not fetched because of an error.
+func (c *Client) collectReceipts(ctx context.Context, ticketIDs []string, pending map[string]*PushResult, h *WorkflowHandle) ([]string, error) {
+	var missing, found []string
+	defer func() {
+		c.forgetWorkflowTickets(ctx, found)
+	}()
+	size := c.maxReceiptBatch()
+	for start := 0; start < len(ticketIDs); start += size {
+		chunk := ticketIDs[start:min(start+size, len(ticketIDs))]
+		receipts, err := c.GetPushReceipts(ctx, chunk)
+		if err != nil {
+			return append(missing, ticketIDs[start:]...), err
+		}
 
-	// Check payload size (rough estimate - actual calculation would be more complex)
-	// The documentation mentions 4096 bytes maximum
-	estimatedSize := len(msg.Title) + len(msg.Body)
-	if msg.Data != nil {
-		for k, v := range msg.Data {
-			estimatedSize += len(k) + len(v)
+		// Step 5: Match receipts to results
+		for _, id := range chunk {
+			receipt, exists := receipts[id]
+			if !exists {
+				missing = append(missing, id)
+				continue
+			}
+			found = append(found, id)
+			result := pending[id]
+			result.PushReceipt = receipt
+			h.update(func(p *WorkflowProgress) {
+				p.Receipts++
+			})
+
+			// Check for specific errors in receipts
+			if !receipt.IsOk() {
+				result.Error = fmt.Errorf("push receipt error: %s", RedactToken(receipt.Message))
+			}
 		}
 	}
-	if estimatedSize > 4000 { // Leave some buffer for JSON structure
-		return fmt.Errorf("message payload too large (estimated %d bytes, maximum ~4000)", estimatedSize)
+	return missing, nil
+}
+
+// fetchOnCancel makes a last attempt at fetching the receipts of ticketIDs
+// once ctx is done, if enabled with WithReceiptFetchOnCancel, and marks the
+// results still without receipt with ErrReceiptUnknown. It returns the error of ctx.
+func (c *Client) fetchOnCancel(ctx context.Context, ticketIDs []string, pending map[string]*PushResult, h *WorkflowHandle) error {
+	if c.cnf.ReceiptFetchOnCancel <= 0 {
+		return ctx.Err()
 	}
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.cnf.ReceiptFetchOnCancel)
+	defer cancel()
+	missing, err := c.collectReceipts(fetchCtx, ticketIDs, pending, h)
+	if err != nil {
+		c.log(ctx, LogWarn, "failed to fetch receipts after cancellation", map[string]string{"error": err.Error()})
+	}
+	for _, id := range missing {
+		pending[id].Error = ErrReceiptUnknown
+	}
+	return ctx.Err()
+}
 
-	// Validate tokens
-	for _, token := range msg.To {
-		if !IsPushTokenValid(string(*token)) {
-			return fmt.Errorf("invalid push token: %s", *token)
-		}
+// IsRetryableReceiptError returns true if a receipt error code indicates the
+// notification may succeed when sent again later
+func IsRetryableReceiptError(code string) bool {
+	return code == string(ErrorMsgRateExceeded)
+}
+
+// retryReceiptErrors resends the recipients whose receipts report a retryable
+// error, backing off between passes, and replaces their results in place
+func (c *Client) retryReceiptErrors(ctx context.Context, results []*PushResult, receiptDelay time.Duration, h *WorkflowHandle) error {
+	retryConfig := c.cnf.ReceiptRetry
+	if retryConfig == nil {
+		return nil
 	}
 
+	for attempt := 1; attempt <= retryConfig.MaxRetries; attempt++ {
+		// Send each affected recipient its own copy of the message
+		indexes := make(map[*Message]int)
+		var msgs []*Message
+		for i, result := range results {
+			if result.PushReceipt == nil || result.Token == nil || result.Message == nil ||
+				!IsRetryableReceiptError(result.ErrorCode()) {
+				continue
+			}
+			msgCopy := *result.Message
+			msgCopy.To = []*Token{result.Token}
+			indexes[&msgCopy] = i
+			msgs = append(msgs, &msgCopy)
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		backoff := retryConfig.ExponentialBackoff(attempt)
+		c.log(ctx, LogInfo, "resending recipients with retryable receipt errors", map[string]string{
+			"attempt": strconv.Itoa(attempt),
+			"count":   strconv.Itoa(len(msgs)),
+			"backoff": backoff.String(),
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.cnf.Clock.After(backoff):
+		}
+
+		retried, err := c.sendAndCollect(ctx, msgs, receiptDelay, h)
+		for _, result := range retried {
+			i, ok := indexes[result.Message]
+			if !ok {
+				continue
+			}
+			result.Message = results[i].Message
+			result.Attempts = results[i].Attempts + 1
+			results[i] = result
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// FilterInvalidTokens removes invalid tokens from messages and returns the count of removed tokens
+// FilterInvalidTokens removes invalid tokens from messages and returns the count of removed tokens.
+//
+// Deprecated: FilterInvalidTokens modifies the messages in place; use
+// WithoutInvalidTokens, which returns cleaned copies instead.
 func FilterInvalidTokens(messages []*Message) int {
 	var removedCount int
 
 	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
 		var validTokens []*Token
 		for _, token := range msg.To {
-			if IsPushTokenValid(string(*token)) {
+			if token != nil && IsPushTokenValid(string(*token)) {
 				validTokens = append(validTokens, token)
 			} else {
 				removedCount++
@@ -147,3 +344,40 @@ func FilterInvalidTokens(messages []*Message) int {
 
 	return removedCount
 }
+
+// RemovedToken describes a token removed by WithoutInvalidTokens
+type RemovedToken struct {
+	// MessageIndex is the position of the message in the input slice
+	MessageIndex int
+	// Position is the index of the token in the message's original To slice
+	Position int
+	// Token is the removed token, or nil if the recipient was missing
+	Token *Token
+}
+
+// WithoutInvalidTokens returns copies of messages with invalid tokens removed,
+// together with every token that was removed. The input is not modified and the
+// result has one message per input message, even if it is left without recipients;
+// nil messages stay nil.
+func WithoutInvalidTokens(messages []*Message) ([]*Message, []RemovedToken) {
+	cleaned := make([]*Message, len(messages))
+	var removed []RemovedToken
+
+	for i, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		msgCopy := *msg
+		msgCopy.To = make([]*Token, 0, len(msg.To))
+		for j, token := range msg.To {
+			if token != nil && IsPushTokenValid(string(*token)) {
+				msgCopy.To = append(msgCopy.To, token)
+			} else {
+				removed = append(removed, RemovedToken{MessageIndex: i, Position: j, Token: token})
+			}
+		}
+		cleaned[i] = &msgCopy
+	}
+
+	return cleaned, removed
+}
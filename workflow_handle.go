@@ -0,0 +1,122 @@
+package expo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WorkflowStage identifies the phase a background workflow is in
+type WorkflowStage int
+
+const (
+	// StagePublishing means the notifications are being sent
+	StagePublishing WorkflowStage = iota
+	// StageWaitingForReceipts means the workflow is waiting for receipts to become available
+	StageWaitingForReceipts
+	// StageFetchingReceipts means the receipts are being fetched
+	StageFetchingReceipts
+	// StageDone means the workflow has finished, successfully or not
+	StageDone
+)
+
+func (s WorkflowStage) String() string {
+	switch s {
+	case StagePublishing:
+		return "publishing"
+	case StageWaitingForReceipts:
+		return "waiting for receipts"
+	case StageFetchingReceipts:
+		return "fetching receipts"
+	case StageDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// WorkflowProgress is a snapshot of a background workflow
+type WorkflowProgress struct {
+	Stage WorkflowStage
+	// Tickets is the number of push tickets that were accepted
+	Tickets int
+	// Failed is the number of push tickets that were rejected
+	Failed int
+	// Receipts is the number of receipts matched to tickets so far
+	Receipts int
+	// ReceiptsDueAt is when receipts will be fetched, once waiting for them
+	ReceiptsDueAt time.Time
+}
+
+// WorkflowHandle tracks a push workflow running in the background
+type WorkflowHandle struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	progress WorkflowProgress
+	results  []*PushResult
+	err      error
+}
+
+// StartPushNotificationsWithReceipts runs SendPushNotificationsWithReceipts in
+// the background and returns immediately with a handle to monitor, await, or
+// cancel it.
+func (c *Client) StartPushNotificationsWithReceipts(ctx context.Context, messages []*Message, receiptDelay time.Duration) *WorkflowHandle {
+	ctx, cancel := context.WithCancel(ctx)
+	h := &WorkflowHandle{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer cancel()
+		var results []*PushResult
+		err := c.safely(ctx, "workflow", func() (err error) {
+			results, err = c.runWorkflow(ctx, messages, receiptDelay, h)
+			return err
+		})
+
+		h.mu.Lock()
+		h.results, h.err = results, err
+		h.progress.Stage = StageDone
+		h.mu.Unlock()
+		close(h.done)
+	}()
+	return h
+}
+
+// Done returns a channel that is closed when the workflow has finished
+func (h *WorkflowHandle) Done() <-chan struct{} {
+	return h.done
+}
+
+// Progress returns a snapshot of the workflow's progress
+func (h *WorkflowHandle) Progress() WorkflowProgress {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.progress
+}
+
+// Cancel aborts the workflow. Results gathered so far remain available through Wait.
+func (h *WorkflowHandle) Cancel() {
+	h.cancel()
+}
+
+// Wait blocks until the workflow has finished and returns its results
+func (h *WorkflowHandle) Wait() ([]*PushResult, error) {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.results, h.err
+}
+
+// update applies fn to the progress; it is a no-op for synchronous workflows
+func (h *WorkflowHandle) update(fn func(p *WorkflowProgress)) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fn(&h.progress)
+}
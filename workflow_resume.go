@@ -0,0 +1,97 @@
+package expo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+type workflowIDKey struct{}
+
+// WithWorkflowID returns a copy of ctx that makes SendPushNotificationsWithReceipts
+// keep the tickets it is waiting for in the store set with WithWorkflowStore,
+// under id. If the process stops before the receipts are fetched, ResumeWorkflow
+// continues where the workflow left off.
+func WithWorkflowID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, workflowIDKey{}, id)
+}
+
+// workflowID returns the workflow ID attached to ctx, if any
+func workflowID(ctx context.Context) string {
+	id, _ := ctx.Value(workflowIDKey{}).(string)
+	return id
+}
+
+// ResumeWorkflow continues checking the receipts of the tickets a workflow
+// run with WithWorkflowID(ctx, id) left outstanding, following the configured
+// receipt schedule, or a single check 15 minutes after the tickets were issued.
+// It returns results for those tickets only, which have no push ticket body
+// beyond their ID, and passes them to the result sink and token cleanup like
+// the workflow does. Without outstanding tickets it returns no results.
+func (c *Client) ResumeWorkflow(ctx context.Context, id string) ([]*PushResult, error) {
+	if c.cnf.WorkflowStore == nil {
+		return nil, errors.New("no workflow store configured")
+	}
+	tickets, err := c.cnf.WorkflowStore.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*PushResult
+	for _, ticket := range tickets {
+		if ticket.WorkflowID != id {
+			continue
+		}
+		results = append(results, &PushResult{
+			TicketID:   ticket.ID,
+			Message:    ticket.Message,
+			Token:      ticket.Token,
+			Meta:       ticket.Meta,
+			PushTicket: &MessageResponse{MessageItem: ticket.Message, Token: ticket.Token, ID: ticket.ID, Status: "ok"},
+			CreatedAt:  ticket.CreatedAt,
+			Attempts:   1,
+		})
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+
+	ctx = WithWorkflowID(ctx, id)
+	err = c.awaitReceipts(ctx, results, 0, nil)
+	return c.finishWorkflow(ctx, results, err, 0, nil)
+}
+
+// saveWorkflow stores the tickets of ticketIDs under the workflow ID of ctx, if any
+func (c *Client) saveWorkflow(ctx context.Context, ticketIDs []string, pending map[string]*PushResult) error {
+	id := workflowID(ctx)
+	if id == "" || c.cnf.WorkflowStore == nil || len(ticketIDs) == 0 {
+		return nil
+	}
+	tickets := make([]*Ticket, len(ticketIDs))
+	for i, ticketID := range ticketIDs {
+		result := pending[ticketID]
+		tickets[i] = &Ticket{
+			ID:         ticketID,
+			Token:      result.Token,
+			Message:    result.Message,
+			Meta:       result.Meta,
+			CreatedAt:  result.CreatedAt,
+			WorkflowID: id,
+		}
+	}
+	if err := c.cnf.WorkflowStore.Add(ctx, tickets); err != nil {
+		return fmt.Errorf("failed to save workflow tickets: %w", err)
+	}
+	return nil
+}
+
+// forgetWorkflowTickets removes tickets that need no more checks from the
+// workflow store. Failures are logged, since the tickets are only checked again.
+func (c *Client) forgetWorkflowTickets(ctx context.Context, ticketIDs []string) {
+	if workflowID(ctx) == "" || c.cnf.WorkflowStore == nil || len(ticketIDs) == 0 {
+		return
+	}
+	if err := c.cnf.WorkflowStore.Remove(ctx, ticketIDs); err != nil {
+		c.log(ctx, LogWarn, "failed to remove workflow tickets", map[string]string{"error": err.Error()})
+	}
+}